@@ -0,0 +1,276 @@
+/*
+The config package is responsible for Juke's user configuration. The
+configuration is kept as JSON in ~/.juke/config.json. Every option has
+a default, so the file only needs to hold what the user wants changed.
+*/
+package config
+
+import (
+	"encoding/json"
+	"github.com/idealeric/juke/log"
+	"os"
+	"os/user"
+	"path"
+)
+
+// Where Juke keeps its files:
+const (
+	DATA_DIRECTORY  = ".juke"       // in the user's home directory
+	CONFIG_FILENAME = "config.json" // in the data directory
+)
+
+// SmartRule is a single condition of a smart playlist. The field is either
+// an MPD tag (genre, artist, album, date, ...), "rating", "plays" or
+// "lastplayed" (the last two come from Juke's listening history).
+//
+// Text operators: is, isnot, contains, notcontains.
+// Number operators: =, !=, <, <=, >, >=.
+// Time operators (lastplayed only): inlast, notinlast (value in days).
+type SmartRule struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+// SmartPlaylist is a named set of rules that picks songs out of the library.
+type SmartPlaylist struct {
+	Name  string      `json:"name"`
+	Match string      `json:"match"` // "all" (the default) or "any" of the rules
+	Rules []SmartRule `json:"rules"`
+	Limit int         `json:"limit"` // 0 is no limit
+	Order string      `json:"order"` // "random", or a field to sort by ("date desc" for descending), library order if empty
+}
+
+// AutoDJ is the configuration of the auto-DJ, which keeps the current
+// playlist topped up. Strategies: "random" (songs from the library),
+// "album" (a whole random album), "artist" or "genre" (songs sharing the
+// current song's artist or genre) and "smart" (songs from a smart playlist).
+type AutoDJ struct {
+	Enabled    bool   `json:"enabled"`
+	MinQueue   int    `json:"min_queue"`   // top up when fewer songs than this follow the current one
+	Strategy   string `json:"strategy"`    // see above
+	Smart      string `json:"smart"`       // name of the smart playlist for the "smart" strategy
+	AvoidHours int    `json:"avoid_hours"` // never pick songs listened to in this many hours
+}
+
+// Party is the configuration of party mode, where guests request songs
+// from a web page served by Juke.
+type Party struct {
+	Listen          string `json:"listen"`            // address the guest page is served on
+	RequireApproval bool   `json:"require_approval"`  // requests wait for the host before being queued
+	RequestsPerHour int    `json:"requests_per_hour"` // per guest
+	LockControls    bool   `json:"lock_controls"`     // lock Juke's own controls while the party is on
+}
+
+// Sorting is the configuration of sorting the current playlist by its
+// column headers.
+type Sorting struct {
+	IgnoreArticles bool     `json:"ignore_articles"` // "The Beatles" sorts under B
+	Articles       []string `json:"articles"`        // the articles ignored
+}
+
+// Column is how a column of the current playlist is laid out. Columns are
+// shown in the order they are listed: name, artist, album, rating,
+// priority, track, disc, duration, genre, date, composer, performer,
+// album_artist, file, position and bitrate.
+type Column struct {
+	Name    string `json:"name"`
+	Visible bool   `json:"visible"`
+	Width   int    `json:"width"` // in pixels, 0 for the default
+}
+
+// Bindings maps commands to the keys of their shortcuts, written as GTK
+// accelerators ("space", "<Control>f", "<Control><Shift>z", ...). Keys
+// given in the configuration replace the defaults one by one, an empty key
+// turns the command's shortcut off.
+type Bindings map[string]string
+
+// Hotkeys is the configuration of the global hotkeys, which reach Juke
+// whatever window has the focus (under X11). Keys are written as bindings
+// are, but with X key names ("XF86AudioPlay", "<Control><Alt>p").
+type Hotkeys struct {
+	Enabled bool              `json:"enabled"`
+	Keys    map[string]string `json:"keys"` // from play_pause, stop, next, previous, volume_up, volume_down and rate_0 to rate_5
+}
+
+// Station is a radio bookmark: a stream that can be added to the current
+// playlist from the main menu.
+type Station struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Progress sets how the progress bar of the current song behaves.
+type Progress struct {
+	ScrollStep int  `json:"scroll_step"` // seconds a scroll of the wheel seeks by
+	Remaining  bool `json:"remaining"`   // show the time remaining rather than elapsed
+}
+
+// Server is a named MPD server profile. The address is either host:port
+// or the path of a unix socket.
+type Server struct {
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	Password string `json:"password"` // empty for none
+}
+
+// Config is the whole of Juke's user configuration.
+type Config struct {
+	Servers        []Server        `json:"servers"`
+	Server         string          `json:"server"`   // name of the profile in use
+	Stickers       bool            `json:"stickers"` // keep ratings and play counts in MPD stickers (if the server can)
+	SmartPlaylists []SmartPlaylist `json:"smart_playlists"`
+	AutoDJ         AutoDJ          `json:"auto_dj"`
+	Party          Party           `json:"party"`
+	Sorting        Sorting         `json:"sorting"`
+	Columns        []Column        `json:"columns"`
+	AlbumView      bool            `json:"album_view"` // group the current playlist by album
+	Bindings       Bindings        `json:"bindings"`
+	Hotkeys        Hotkeys         `json:"hotkeys"`
+	Progress       Progress        `json:"progress"`
+	Radio          []Station       `json:"radio"`
+}
+
+// The configuration in use, initially just the defaults.
+var current *Config = defaults()
+
+// defaults returns a configuration with every option at its default.
+func defaults() *Config {
+
+	return &Config{
+		Servers: []Server{
+			{Name: "Local", Address: "127.0.0.1:6600"}},
+		Stickers: true,
+		AutoDJ: AutoDJ{
+			MinQueue:   3,
+			Strategy:   "random",
+			AvoidHours: 24},
+		Party: Party{
+			Listen:          ":6680",
+			RequestsPerHour: 5},
+		Sorting: Sorting{
+			IgnoreArticles: true,
+			Articles:       []string{"the", "a", "an"}},
+		Columns: []Column{
+			{Name: "name", Visible: true},
+			{Name: "artist", Visible: true},
+			{Name: "album", Visible: true},
+			{Name: "rating", Visible: true},
+			{Name: "priority", Visible: true}},
+		Bindings: Bindings{
+			"play_pause":    "space",
+			"stop":          "<Control>s",
+			"next":          "<Control>Right",
+			"previous":      "<Control>Left",
+			"seek_forward":  "Right",
+			"seek_backward": "Left",
+			"volume_up":     "<Control>Up",
+			"volume_down":   "<Control>Down",
+			"remove":        "Delete",
+			"play_next":     "<Control>n",
+			"undo":          "<Control>z",
+			"redo":          "<Control><Shift>z",
+			"find":          "<Control>f",
+			"shortcuts":     "F1",
+			"palette":       "<Control><Shift>p"},
+		Hotkeys: Hotkeys{
+			Keys: map[string]string{
+				"play_pause":  "XF86AudioPlay",
+				"stop":        "XF86AudioStop",
+				"next":        "XF86AudioNext",
+				"previous":    "XF86AudioPrev",
+				"volume_up":   "XF86AudioRaiseVolume",
+				"volume_down": "XF86AudioLowerVolume",
+				"rate_1":      "<Control><Super>1",
+				"rate_2":      "<Control><Super>2",
+				"rate_3":      "<Control><Super>3",
+				"rate_4":      "<Control><Super>4",
+				"rate_5":      "<Control><Super>5"}},
+		Progress: Progress{
+			ScrollStep: 5}}
+
+} // end defaults
+
+// DataDir returns Juke's data directory, creating it if need be.
+func DataDir() (string, error) {
+
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+
+	dataDir := path.Join(usr.HomeDir, DATA_DIRECTORY)
+	if errMkdir := os.MkdirAll(dataDir, 0755); errMkdir != nil {
+		return "", errMkdir
+	}
+
+	return dataDir, nil
+
+} // end DataDir
+
+// Load reads the configuration file over the defaults. A missing file is
+// not an error, the defaults are simply used.
+func Load() {
+
+	dataDir, errDir := DataDir()
+	if errDir != nil {
+		log.ErrorReport("config.Load()", "Could not establish the data directory ("+errDir.Error()+").")
+		return
+	}
+
+	contents, errRead := os.ReadFile(path.Join(dataDir, CONFIG_FILENAME))
+	if errRead != nil {
+		if !os.IsNotExist(errRead) {
+			log.ErrorReport("config.Load()", "Could not read the configuration ("+errRead.Error()+").")
+		}
+		return
+	}
+
+	loaded := defaults()
+	if errJSON := json.Unmarshal(contents, loaded); errJSON != nil {
+		log.ErrorReport("config.Load()", "Could not parse the configuration, using defaults ("+errJSON.Error()+").")
+		return
+	}
+	current = loaded
+
+} // end Load
+
+// Save writes the configuration in use back to the configuration file.
+func Save() error {
+
+	dataDir, errDir := DataDir()
+	if errDir != nil {
+		return errDir
+	}
+
+	contents, errJSON := json.MarshalIndent(current, "", "\t")
+	if errJSON != nil {
+		return errJSON
+	}
+
+	return os.WriteFile(path.Join(dataDir, CONFIG_FILENAME), append(contents, '\n'), 0644)
+
+} // end Save
+
+// Get returns the configuration in use.
+func Get() *Config {
+
+	return current
+
+} // end Get
+
+// CurrentServer returns the server profile in use: the one named by Server,
+// or else the first one.
+func (config *Config) CurrentServer() *Server {
+
+	for i := range config.Servers {
+		if config.Servers[i].Name == config.Server {
+			return &config.Servers[i]
+		}
+	}
+	if len(config.Servers) == 0 {
+		config.Servers = defaults().Servers
+	}
+	return &config.Servers[0]
+
+} // end CurrentServer
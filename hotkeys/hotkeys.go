@@ -0,0 +1,55 @@
+/*
+The hotkeys package grabs Juke's global hotkeys: keys (such as the media
+keys) that reach Juke whatever window has the focus. They are grabbed from
+the X server named by $DISPLAY, over a connection of their own.
+
+Keys are written as GTK accelerators but with X key names, such as
+"XF86AudioPlay" or "<Control><Alt>p".
+*/
+package hotkeys
+
+import (
+	"strings"
+)
+
+// The modifiers a hotkey can use, as X masks. The others (caps lock, num
+// lock, ...) are ignored.
+const (
+	SHIFT_MASK   uint = 1 << 0
+	CONTROL_MASK uint = 1 << 2
+	MOD1_MASK    uint = 1 << 3 // Alt
+	MOD4_MASK    uint = 1 << 6 // Super
+	HOTKEY_MASKS      = SHIFT_MASK | CONTROL_MASK | MOD1_MASK | MOD4_MASK
+)
+
+// Masks grabbed along with each hotkey, so that it works whether caps
+// lock (LockMask) and num lock (Mod2Mask) are on or not.
+var lockMasks = []uint{0, 1 << 1, 1 << 4, 1<<1 | 1<<4}
+
+// splitAccelerator splits keys into their modifiers and the name of the
+// key itself.
+func splitAccelerator(accelerator string) (uint, string, bool) {
+
+	var modifiers uint
+	for strings.HasPrefix(accelerator, "<") {
+		end := strings.Index(accelerator, ">")
+		if end < 0 {
+			return 0, "", false
+		}
+		switch strings.ToLower(accelerator[1:end]) {
+		case "control", "ctrl", "primary":
+			modifiers |= CONTROL_MASK
+		case "shift":
+			modifiers |= SHIFT_MASK
+		case "alt", "mod1":
+			modifiers |= MOD1_MASK
+		case "super", "mod4":
+			modifiers |= MOD4_MASK
+		default:
+			return 0, "", false
+		}
+		accelerator = accelerator[end+1:]
+	}
+	return modifiers, accelerator, accelerator != ""
+
+} // end splitAccelerator
@@ -0,0 +1,22 @@
+//go:build !cgo || windows || darwin
+// +build !cgo windows darwin
+
+/*
+This file is part of Juke MPD client hotkeys package. See juke.go for more
+details.
+
+This particular file stands in for Xlib where there is none.
+*/
+
+package hotkeys
+
+import (
+	"errors"
+)
+
+// Listen would grab the hotkeys, there is no X server to grab them from.
+func Listen(bindings map[string]string) (<-chan string, error) {
+
+	return nil, errors.New("global hotkeys need X11")
+
+} // end Listen
@@ -0,0 +1,130 @@
+//go:build cgo && !windows && !darwin
+// +build cgo,!windows,!darwin
+
+/*
+This file is part of Juke MPD client hotkeys package. See juke.go for more
+details.
+
+This particular file grabs the hotkeys through Xlib.
+*/
+
+package hotkeys
+
+/*
+#cgo LDFLAGS: -lX11
+#include <stdlib.h>
+#include <X11/Xlib.h>
+
+static Display *grabbing;
+static int grabFailed;
+static XErrorHandler previousHandler;
+
+// noteError notes the errors of the grabbing connection, any other error
+// (GTK's own) is passed on.
+static int noteError(Display *display, XErrorEvent *event) {
+	if (display == grabbing) {
+		grabFailed = 1;
+		return 0;
+	}
+	return previousHandler ? previousHandler(display, event) : 0;
+}
+
+// grabKey grabs a key on the root window, returning whether it could be
+// (another program may have it).
+static int grabKey(Display *display, int keycode, unsigned int modifiers) {
+	grabbing = display;
+	grabFailed = 0;
+	previousHandler = XSetErrorHandler(noteError);
+	XGrabKey(display, keycode, modifiers, DefaultRootWindow(display), False, GrabModeAsync, GrabModeAsync);
+	XSync(display, False);
+	XSetErrorHandler(previousHandler);
+	return !grabFailed;
+}
+
+// nextKey waits for a hotkey to be pressed, returning its keycode and
+// modifiers.
+static int nextKey(Display *display, unsigned int *modifiers) {
+	XEvent event;
+	for (;;) {
+		XNextEvent(display, &event);
+		if (event.type == KeyPress) {
+			*modifiers = event.xkey.state;
+			return event.xkey.keycode;
+		}
+	}
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"github.com/idealeric/juke/log"
+	"runtime"
+	"unsafe"
+)
+
+// hotkey is a grabbed key with its modifiers.
+type hotkey struct {
+	keycode   int
+	modifiers uint
+}
+
+// Listen grabs the hotkeys, from their names to their keys, and sends the
+// name of every hotkey pressed on the channel returned. Keys that are
+// unknown or taken by another program are reported and left out.
+func Listen(bindings map[string]string) (<-chan string, error) {
+
+	display := C.XOpenDisplay(nil)
+	if display == nil {
+		return nil, errors.New("could not open the X display")
+	}
+
+	grabbed := make(map[hotkey]string)
+	for name, accelerator := range bindings {
+		if accelerator == "" {
+			continue
+		}
+		modifiers, keyName, valid := splitAccelerator(accelerator)
+		if !valid {
+			log.ErrorReport("hotkeys.Listen()", "Could not read the key "+accelerator+" of "+name+".")
+			continue
+		}
+		cKeyName := C.CString(keyName)
+		keysym := C.XStringToKeysym(cKeyName)
+		C.free(unsafe.Pointer(cKeyName))
+		keycode := int(C.XKeysymToKeycode(display, keysym))
+		if keysym == C.NoSymbol || keycode == 0 {
+			log.ErrorReport("hotkeys.Listen()", "There is no key "+keyName+" on this keyboard (for "+name+").")
+			continue
+		}
+		key := hotkey{keycode, modifiers}
+		if other, taken := grabbed[key]; taken {
+			log.ErrorReport("hotkeys.Listen()", "The key "+accelerator+" is bound to both "+other+" and "+name+".")
+			continue
+		}
+		free := true
+		for _, lockMask := range lockMasks {
+			free = free && C.grabKey(display, C.int(keycode), C.uint(modifiers|lockMask)) != 0
+		}
+		if !free {
+			log.ErrorReport("hotkeys.Listen()", "Could not grab "+accelerator+" for "+name+", another program has it.")
+			continue
+		}
+		grabbed[key] = name
+	}
+
+	pressed := make(chan string, 8)
+	go func() {
+		// Xlib is only ever used from this thread.
+		runtime.LockOSThread()
+		for {
+			var modifiers C.uint
+			keycode := int(C.nextKey(display, &modifiers))
+			if name, bound := grabbed[hotkey{keycode, uint(modifiers) & HOTKEY_MASKS}]; bound {
+				pressed <- name
+			}
+		}
+	}()
+	return pressed, nil
+
+} // end Listen
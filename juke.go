@@ -20,6 +20,7 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package main
 
 import (
+	"github.com/idealeric/juke/config"
 	"github.com/idealeric/juke/ui"
 )
 
@@ -27,16 +28,57 @@ import (
 func main() {
 
 	var updateChannel chan *jukeRequest = make(chan *jukeRequest)
+	var done chan bool = make(chan bool)
+
+	config.Load()
 
 	ui.InitInterface()
 
-	go update(updateChannel)
+	smartNames := make([]string, len(config.Get().SmartPlaylists))
+	for i, smart := range config.Get().SmartPlaylists {
+		smartNames[i] = smart.Name
+	}
+	ui.SetSmartPlaylists(smartNames)
+
+	radioNames := make([]string, len(config.Get().Radio))
+	for i, station := range config.Get().Radio {
+		radioNames[i] = station.Name
+	}
+	ui.SetRadioStations(radioNames)
+
+	currentServer := config.Get().CurrentServer().Name
+	serverNames := make([]string, len(config.Get().Servers))
+	current := 0
+	for i, server := range config.Get().Servers {
+		serverNames[i] = server.Name
+		if server.Name == currentServer {
+			current = i
+		}
+	}
+	ui.SetServers(serverNames, current)
+	ui.SetAutoDJ(config.Get().AutoDJ.Enabled)
+	ui.SetColumnLayout(columnLayout(config.Get().Columns))
+	ui.SetAlbumView(config.Get().AlbumView)
+	ui.SetProgressSettings(config.Get().Progress.ScrollStep, config.Get().Progress.Remaining)
+
+	go update(updateChannel, done)
 
 	// For code tidyness, callbacks are defined in a seperate file.
 	initCallBacks(updateChannel)
+	// Binding the callbacks registers the commands the keys are bound to.
+	// update() is already running, so the UI is locked.
+	ui.Lock()
+	ui.SetBindings(config.Get().Bindings)
+	ui.Unlock()
+	startHotkeys(updateChannel, done)
 
 	ui.MainLoop() // This blocks until the GUI is destoryed.
 
-	close(updateChannel) // Tells update to shut off
+	// The column widths are saved as they were left.
+	if layout := ui.ColumnLayout(); layout != nil {
+		saveColumns(layout)
+	}
+
+	close(done) // Tells update (and whatever sends to it) to shut off
 
 } // end main
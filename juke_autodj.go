@@ -0,0 +1,253 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has Juke's auto-DJ (keeps the current playlist topped up).
+*/
+
+package main
+
+import (
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/config"
+	"github.com/idealeric/juke/log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// autoDJ remembers which playlist state it last looked at, so that it
+// only does work when the playlist or current song has moved on. It also
+// keeps which songs of the playlist were played while in random mode and
+// when each file was last listened to (read from the history once, then
+// kept up to date as songs finish).
+type autoDJ struct {
+	checked string
+	played  map[string]bool      // song IDs played in random mode
+	recent  map[string]time.Time // by URI, nil until the history is read
+}
+
+// reset forgets the playlist, for use on a new connection.
+func (dj *autoDJ) reset() {
+
+	dj.checked = ""
+	dj.played = nil
+
+} // end reset
+
+// listened records that a file was just listened to.
+func (dj *autoDJ) listened(file string) {
+
+	if dj.recent != nil {
+		dj.recent[file] = time.Now()
+	}
+
+} // end listened
+
+// unplayed counts the songs of the playlist that random mode has yet to
+// play, taking the current song as played.
+func (dj *autoDJ) unplayed(mpdConnection *mpd.Client, curId string) (int, error) {
+
+	curPlay, errPlay := mpdConnection.PlaylistInfo(-1, -1)
+	if errPlay != nil {
+		return 0, errPlay
+	}
+
+	if dj.played == nil {
+		dj.played = make(map[string]bool)
+	}
+	dj.played[curId] = true
+
+	// Songs that left the playlist are forgotten.
+	played := make(map[string]bool)
+	unplayed := 0
+	for _, song := range curPlay {
+		if dj.played[song["Id"]] {
+			played[song["Id"]] = true
+		} else {
+			unplayed++
+		}
+	}
+	dj.played = played
+	return unplayed, nil
+
+} // end unplayed
+
+// avoided returns the songs that must not be picked: anything already in
+// the current playlist and anything listened to recently.
+func (dj *autoDJ) avoided(mpdConnection *mpd.Client) map[string]bool {
+
+	avoid := make(map[string]bool)
+
+	if curPlay, errPlay := mpdConnection.PlaylistInfo(-1, -1); errPlay == nil {
+		for _, song := range curPlay {
+			avoid[song["file"]] = true
+		}
+	}
+
+	if dj.recent == nil {
+		dj.recent = make(map[string]time.Time)
+		for _, entry := range readHistory() {
+			dj.recent[entry.file] = entry.when
+		}
+	}
+	since := time.Now().Add(-time.Duration(config.Get().AutoDJ.AvoidHours) * time.Hour)
+	for file, when := range dj.recent {
+		if when.After(since) {
+			avoid[file] = true
+		}
+	}
+
+	return avoid
+
+} // end avoided
+
+// pickRandom picks up to count random songs out of candidates.
+func pickRandom(candidates []mpd.Attrs, avoid map[string]bool, count int) []string {
+
+	var uris []string
+	for _, i := range rand.Perm(len(candidates)) {
+		if len(uris) == count {
+			break
+		}
+		if !avoid[candidates[i]["file"]] {
+			uris = append(uris, candidates[i]["file"])
+		}
+	}
+	return uris
+
+} // end pickRandom
+
+// pickAlbum picks a whole random album (in library order, which for most
+// libraries is track order).
+func pickAlbum(candidates []mpd.Attrs, avoid map[string]bool) []string {
+
+	albums := make(map[string][]string)
+	var albumNames []string
+	for _, song := range candidates {
+		if song["Album"] == "" || avoid[song["file"]] {
+			continue
+		}
+		albumKey := songTag(song, "AlbumArtist") + "\x00" + song["Album"]
+		if _, exists := albums[albumKey]; !exists {
+			albumNames = append(albumNames, albumKey)
+		}
+		albums[albumKey] = append(albums[albumKey], song["file"])
+	}
+
+	if len(albumNames) == 0 {
+		return nil
+	}
+	return albums[albumNames[rand.Intn(len(albumNames))]]
+
+} // end pickAlbum
+
+// sharing returns the songs of the library that share a tag with a song.
+func sharing(library []mpd.Attrs, song mpd.Attrs, tag string) []mpd.Attrs {
+
+	var sharing []mpd.Attrs
+	if value := song[tag]; value != "" {
+		for _, candidate := range library {
+			if strings.EqualFold(candidate[tag], value) {
+				sharing = append(sharing, candidate)
+			}
+		}
+	}
+	return sharing
+
+} // end sharing
+
+// pick chooses songs according to the configured strategy, falling back
+// to random songs from the library when the strategy comes up empty.
+func (dj *autoDJ) pick(mpdConnection *mpd.Client, stickers *stickerSupport, library *libraryCache, curSong mpd.Attrs, count int) []string {
+
+	avoid := dj.avoided(mpdConnection)
+	var uris []string
+
+	switch strings.ToLower(config.Get().AutoDJ.Strategy) {
+	case "album":
+		uris = pickAlbum(library.songs, avoid)
+	case "artist":
+		uris = pickRandom(sharing(library.songs, curSong, "Artist"), avoid, count)
+	case "genre":
+		uris = pickRandom(sharing(library.songs, curSong, "Genre"), avoid, count)
+	case "smart":
+		if playlist := findSmartPlaylist(config.Get().AutoDJ.Smart); playlist == nil {
+			log.ErrorReport("autoDJ.pick()", "No smart playlist named "+config.Get().AutoDJ.Smart+".")
+		} else if smartUris, errEval := evaluateSmartPlaylist(mpdConnection, stickers, library, playlist); errEval != nil {
+			log.ErrorReport("autoDJ.pick()", "Could not evaluate smart playlist ("+errEval.Error()+").")
+		} else {
+			for _, uri := range smartUris {
+				if len(uris) == count {
+					break
+				}
+				if !avoid[uri] {
+					uris = append(uris, uri)
+				}
+			}
+		}
+	}
+
+	if len(uris) == 0 {
+		uris = pickRandom(library.songs, avoid, count)
+	}
+	return uris
+
+} // end pick
+
+// check is called on every poll while playing. When fewer than the
+// configured number of songs follow the current one, more are appended.
+func (dj *autoDJ) check(mpdConnection *mpd.Client, stickers *stickerSupport, library *libraryCache, status, curSong mpd.Attrs) {
+
+	if !config.Get().AutoDJ.Enabled {
+		return
+	}
+
+	// Nothing has changed since the last look, so there is nothing to do.
+	checked := status["playlist"] + ":" + status["song"]
+	if checked == dj.checked {
+		return
+	}
+	dj.checked = checked
+
+	length, errLength := strconv.Atoi(status["playlistlength"])
+	song, errSong := strconv.Atoi(status["song"])
+	if errLength != nil || errSong != nil {
+		return
+	}
+	remaining := length - song - 1
+	if status["random"] == "1" {
+		// In random mode, any song not yet played may follow.
+		var errUnplayed error
+		if remaining, errUnplayed = dj.unplayed(mpdConnection, status["songid"]); errUnplayed != nil {
+			log.ErrorReport("autoDJ.check()", "Could not list the current playlist ("+errUnplayed.Error()+").")
+			return
+		}
+	} else {
+		dj.played = nil
+	}
+	if remaining >= config.Get().AutoDJ.MinQueue {
+		return
+	}
+
+	if errLibrary := library.refresh(mpdConnection); errLibrary != nil {
+		log.ErrorReport("autoDJ.check()", "Could not list the library ("+errLibrary.Error()+").")
+		return
+	}
+
+	uris := dj.pick(mpdConnection, stickers, library, curSong, config.Get().AutoDJ.MinQueue-remaining)
+	if len(uris) == 0 {
+		return
+	}
+
+	cmdList := mpdConnection.BeginCommandList()
+	for _, uri := range uris {
+		cmdList.Add(uri)
+	}
+	if cmdErr := cmdList.End(); cmdErr != nil {
+		log.ErrorReport("autoDJ.check()", "Could not end the command list ("+cmdErr.Error()+").")
+	} else {
+		log.MessageReport("autoDJ.check()", "Added "+strconv.Itoa(len(uris))+" songs to the current playlist.")
+	}
+
+} // end check
@@ -7,6 +7,7 @@ This particular file has all of Juke's callback bindings.
 package main
 
 import (
+	"github.com/idealeric/juke/config"
 	"github.com/idealeric/juke/ui"
 )
 
@@ -47,6 +48,20 @@ func initCallBacks(updateChannel chan *jukeRequest) {
 		return nil
 	})
 
+	ui.RemainingToggle(func(remaining bool) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: REMAINING_TOGGLE, enable: remaining}
+		}()
+		return nil
+	})
+
+	ui.KeySeek(func(seconds int) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: SEEK_RELATIVE, seek: seconds}
+		}()
+		return nil
+	})
+
 	ui.ConnectionClick(func() error {
 		go func() {
 			updateChannel <- &jukeRequest{state: CONNECTION_REFREASH}
@@ -54,6 +69,111 @@ func initCallBacks(updateChannel chan *jukeRequest) {
 		return nil
 	})
 
+	ui.ServerSwitch(func(index int) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: SWITCH_SERVER, name: config.Get().Servers[index].Name}
+		}()
+		return nil
+	})
+
+	ui.SendPlayback(func(index int, stopHere bool) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: SEND_PLAYBACK, name: config.Get().Servers[index].Name, save: stopHere}
+		}()
+		return nil
+	})
+
+	ui.MirrorToggle(func(index int, enable bool) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: MIRROR_TOGGLE, name: config.Get().Servers[index].Name, enable: enable}
+		}()
+		return nil
+	})
+
+	ui.GroupToggle(func(index int, enable bool) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: GROUP_TOGGLE, name: config.Get().Servers[index].Name, enable: enable}
+		}()
+		return nil
+	})
+
+	ui.VolumeChange(func(volume int) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: VOLUME_CHANGE, volume: volume}
+		}()
+		return nil
+	})
+
+	ui.OutputsOpen(func() error {
+		go func() {
+			updateChannel <- &jukeRequest{state: OUTPUTS_REFRESH}
+		}()
+		return nil
+	})
+
+	ui.OutputChange(func(id int, action ui.OutputAction) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: OUTPUT_CHANGE, outputId: id, outputAction: action}
+		}()
+		return nil
+	})
+
+	ui.OutputMove(func(name, partition string) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: OUTPUT_MOVE, name: name, partition: partition}
+		}()
+		return nil
+	})
+
+	ui.PartitionsOpen(func() error {
+		go func() {
+			updateChannel <- &jukeRequest{state: PARTITIONS_REFRESH}
+		}()
+		return nil
+	})
+
+	ui.PartitionSwitch(func(partition string) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: PARTITION_SWITCH, partition: partition}
+		}()
+		return nil
+	})
+
+	ui.PartitionNew(func(partition string) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: PARTITION_NEW, partition: partition}
+		}()
+		return nil
+	})
+
+	ui.PartitionDelete(func(partition string) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: PARTITION_DELETE, partition: partition}
+		}()
+		return nil
+	})
+
+	ui.SettingsOpen(func() error {
+		go func() {
+			updateChannel <- &jukeRequest{state: SETTINGS_REFRESH}
+		}()
+		return nil
+	})
+
+	ui.SettingChange(func(setting ui.PlaybackSetting, value float64) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: SETTING_CHANGE, setting: setting, value: value}
+		}()
+		return nil
+	})
+
+	ui.CurrentQueueOperation(func(operation ui.QueueOperation, priority int, ids []int) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: QUEUE_OPERATION, queueOp: operation, priority: priority, ids: ids}
+		}()
+		return nil
+	})
+
 	ui.CurrentRowDoubleClick(func(row *ui.CurrentPLRow) error {
 		go func() {
 			updateChannel <- &jukeRequest{state: CHANGE_TRACK, clickedRow: row}
@@ -61,9 +181,16 @@ func initCallBacks(updateChannel chan *jukeRequest) {
 		return nil
 	})
 
-	ui.CurrentColumnClick(func(rc chan *ui.CurrentPLRow) error {
+	ui.CurrentRatingClick(func(row *ui.CurrentPLRow, rating int) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: RATE_SONG, clickedRow: row, rating: rating}
+		}()
+		return nil
+	})
+
+	ui.CurrentColumnClick(func(keys []ui.SortKey, descending bool) error {
 		go func() {
-			updateChannel <- &jukeRequest{state: SORT_PLAYLIST, playlistChan: rc}
+			updateChannel <- &jukeRequest{state: SORT_PLAYLIST, sortKeys: keys, descending: descending}
 		}()
 		return nil
 	})
@@ -82,4 +209,97 @@ func initCallBacks(updateChannel chan *jukeRequest) {
 		return nil
 	})
 
+	ui.CurrentUndo(func(redo bool) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: UNDO_QUEUE, redo: redo}
+		}()
+		return nil
+	})
+
+	ui.ColumnsChange(func(layout []ui.ColumnSetting) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: COLUMNS_CHANGE, columns: layout}
+		}()
+		return nil
+	})
+
+	ui.AlbumViewToggle(func(enabled bool) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: ALBUM_VIEW_TOGGLE, enable: enabled}
+		}()
+		return nil
+	})
+
+	ui.StatisticsOpen(func(statsRange ui.StatsRange) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: SHOW_STATISTICS, statsRange: statsRange}
+		}()
+		return nil
+	})
+
+	ui.StatisticsRowDoubleClick(func(file string) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: ADD_SONGS, uris: []string{file}}
+		}()
+		return nil
+	})
+
+	ui.PaletteSearch(func(query string) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: PALETTE_SEARCH, name: query}
+		}()
+		return nil
+	})
+
+	ui.PaletteAddSong(func(file string) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: ADD_SONGS, uris: []string{file}}
+		}()
+		return nil
+	})
+
+	ui.ScrobbleExport(func(format ui.ScrobbleFormat, filename string) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: EXPORT_SCROBBLES, exportFormat: format, exportFile: filename}
+		}()
+		return nil
+	})
+
+	ui.SmartPlaylistClick(func(index int, save bool) error {
+		name := config.Get().SmartPlaylists[index].Name
+		go func() {
+			updateChannel <- &jukeRequest{state: SMART_PLAYLIST, name: name, save: save}
+		}()
+		return nil
+	})
+
+	ui.RadioClick(func(index int) error {
+		url := config.Get().Radio[index].URL
+		go func() {
+			updateChannel <- &jukeRequest{state: ADD_SONGS, uris: []string{url}}
+		}()
+		return nil
+	})
+
+	ui.AutoDJToggle(func(enable bool) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: AUTODJ_TOGGLE, enable: enable}
+		}()
+		return nil
+	})
+
+	ui.PartyToggle(func(enable bool) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: PARTY_TOGGLE, enable: enable}
+		}()
+		return nil
+	})
+
+	ui.PartyDecide(func(id int, approve bool) error {
+		go func() {
+			updateChannel <- &jukeRequest{state: PARTY_DECIDE, partyId: id, enable: approve}
+		}()
+		return nil
+	})
+
 } // end initCallbacks
@@ -9,10 +9,10 @@ package main
 import (
 	"container/list"
 	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/config"
 	"github.com/idealeric/juke/log"
 	"github.com/idealeric/juke/ui"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -42,6 +42,42 @@ const (
 	REMOVE_PLAYLIST
 	CLEAR_PLAYLIST
 	CONNECTION_REFREASH
+	SHOW_STATISTICS
+	ADD_SONGS
+	EXPORT_SCROBBLES
+	RATE_SONG
+	SMART_PLAYLIST
+	AUTODJ_TOGGLE
+	PARTY_TOGGLE
+	PARTY_SEARCH
+	PARTY_LOOKUP
+	PARTY_SYNC
+	PARTY_DECIDE
+	SWITCH_SERVER
+	SEND_PLAYBACK
+	MIRROR_TOGGLE
+	GROUP_TOGGLE
+	VOLUME_CHANGE
+	OUTPUTS_REFRESH
+	OUTPUT_CHANGE
+	OUTPUT_MOVE
+	PARTITIONS_REFRESH
+	PARTITION_SWITCH
+	PARTITION_NEW
+	PARTITION_DELETE
+	SETTINGS_REFRESH
+	SETTING_CHANGE
+	QUEUE_OPERATION
+	UNDO_QUEUE
+	COLUMNS_CHANGE
+	ALBUM_VIEW_TOGGLE
+	REMAINING_TOGGLE
+	SEEK_RELATIVE
+	PALETTE_SEARCH
+	HOTKEY_PRESS
+	VOLUME_STEP
+	RATE_CURRENT
+	PLAYBACK_SENT
 )
 
 // update() accepts jukeRequests, which consist in a jukeStateRequest and any other
@@ -50,8 +86,34 @@ type jukeRequest struct {
 	state         jukeStateRequest      // request type
 	progressX     int                   // x value of the PROGRESS_CHANGE event request
 	progressWidth int                   // width progressbar on PROGRESS_CHANGE request
-	clickedRow    *ui.CurrentPLRow      // row that is clicked on CHANGE_TRACK request
-	playlistChan  chan *ui.CurrentPLRow // chan for rows on SORT_PLAYLIST request
+	seek          int                   // seconds to seek by on SEEK_RELATIVE request
+	clickedRow    *ui.CurrentPLRow      // row that is clicked on CHANGE_TRACK/RATE_SONG request
+	rating        int                   // new rating on RATE_SONG/RATE_CURRENT request
+	playlistChan  chan *ui.CurrentPLRow // chan for rows on REMOVE_PLAYLIST request
+	sortKeys      []ui.SortKey          // keys of the SORT_PLAYLIST request
+	descending    bool                  // direction of the SORT_PLAYLIST request
+	statsRange    ui.StatsRange         // time range on SHOW_STATISTICS request
+	uris          []string              // songs to append on ADD_SONGS request
+	exportFormat  ui.ScrobbleFormat     // format of the EXPORT_SCROBBLES request
+	exportFile    string                // file to write on EXPORT_SCROBBLES request
+	name          string                // name of the SMART_PLAYLIST/SWITCH_SERVER/SEND_PLAYBACK/PLAYBACK_SENT/MIRROR_TOGGLE/GROUP_TOGGLE/OUTPUT_MOVE/HOTKEY_PRESS request, query of the PARTY_SEARCH/PALETTE_SEARCH request, uri of the PARTY_LOOKUP request
+	save          bool                  // save (rather than add) on SMART_PLAYLIST request, stop the source on SEND_PLAYBACK/PLAYBACK_SENT request
+	enable        bool                  // new setting on AUTODJ_TOGGLE/PARTY_TOGGLE/MIRROR_TOGGLE/GROUP_TOGGLE/ALBUM_VIEW_TOGGLE/REMAINING_TOGGLE request, approval on PARTY_DECIDE request
+	volume        int                   // new volume on VOLUME_CHANGE request, change of it on VOLUME_STEP request
+	outputId      int                   // output of the OUTPUT_CHANGE request
+	outputAction  ui.OutputAction       // action of the OUTPUT_CHANGE request
+	partition     string                // destination of the OUTPUT_MOVE request, partition of the PARTITION_* requests
+	setting       ui.PlaybackSetting    // setting of the SETTING_CHANGE request
+	value         float64               // new value on SETTING_CHANGE request
+	queueOp       ui.QueueOperation     // operation of the QUEUE_OPERATION request
+	priority      int                   // priority set on QUEUE_OPERATION request
+	ids           []int                 // selected songs on QUEUE_OPERATION request
+	redo          bool                  // redo (rather than undo) on UNDO_QUEUE request
+	columns       []ui.ColumnSetting    // new layout on COLUMNS_CHANGE request
+	partyReply    chan []partyResult    // chan for results on PARTY_SEARCH/PARTY_LOOKUP request
+	partyId       int                   // request decided on PARTY_DECIDE request
+	pollReply     chan int              // chan of the poll loop that sent the POLL_REFREASH request
+	err           error                 // failure (if any) of the PLAYBACK_SENT request
 }
 
 // Variable rate at which juke will poll MPD, in ms
@@ -63,7 +125,7 @@ const (
 )
 
 // updateSongList fills the current playlist.
-func updateSongList(mpdConnection *mpd.Client, status mpd.Attrs, curPLVersion int) int {
+func updateSongList(mpdConnection *mpd.Client, stickers *stickerSupport, status mpd.Attrs, curPLVersion int) int {
 
 	if reportPLVersion, errPLVersion := strconv.Atoi(status["playlist"]); errPLVersion != nil {
 		log.ErrorReport("update() POLL_REFREASH", "Unable to convert the playlist version to a number.")
@@ -74,26 +136,55 @@ func updateSongList(mpdConnection *mpd.Client, status mpd.Attrs, curPLVersion in
 		} else {
 
 			rows := make([]*ui.CurrentPLRow, len(curPlay))
+			ratings := stickers.ratings(mpdConnection)
 			ui.ClearCurrentPlaylist()
 
 			for i, r := range curPlay {
+				// MPD leaves out the priority of songs without one.
+				priority, _ := strconv.Atoi(r["Prio"])
+				duration, errDuration := strconv.Atoi(r["Time"])
+				if errDuration != nil {
+					duration = -1
+				}
 				if rId, errId := strconv.Atoi(r["Id"]); errId != nil {
 					log.ErrorReport("update() POLL_REFREASH", "Could not convert songid ("+errId.Error()+").")
 				} else if status["songid"] == r["Id"] {
 					rows[i] = &ui.CurrentPLRow{
 						ID:          rId,
 						ArtworkPath: albumArtFilename(r["file"]),
-						Name:        r["Title"],
+						File:        r["file"],
+						Name:        songName(r),
 						Artist:      r["Artist"],
 						Album:       r["Album"],
+						Rating:      ratings[r["file"]],
+						Priority:    priority,
+						Track:       r["Track"],
+						Disc:        r["Disc"],
+						Duration:    duration,
+						Genre:       r["Genre"],
+						Date:        r["Date"],
+						Composer:    r["Composer"],
+						Performer:   r["Performer"],
+						AlbumArtist: r["AlbumArtist"],
 						Bold:        true}
 				} else {
 					rows[i] = &ui.CurrentPLRow{
 						ID:          rId,
 						ArtworkPath: albumArtFilename(r["file"]),
-						Name:        r["Title"],
+						File:        r["file"],
+						Name:        songName(r),
 						Artist:      r["Artist"],
 						Album:       r["Album"],
+						Rating:      ratings[r["file"]],
+						Priority:    priority,
+						Track:       r["Track"],
+						Disc:        r["Disc"],
+						Duration:    duration,
+						Genre:       r["Genre"],
+						Date:        r["Date"],
+						Composer:    r["Composer"],
+						Performer:   r["Performer"],
+						AlbumArtist: r["AlbumArtist"],
 						Bold:        false}
 				}
 			}
@@ -117,14 +208,68 @@ func updateSongList(mpdConnection *mpd.Client, status mpd.Attrs, curPLVersion in
 
 } // end updateSongList
 
+// localRequest handles the requests that only concern Juke's own files and
+// settings, which are available whether or not MPD is connected. True is
+// returned if the request was one of them.
+func localRequest(request *jukeRequest) bool {
+
+	switch request.state {
+
+	case SHOW_STATISTICS:
+
+		ui.Lock()
+		showStatistics(request.statsRange)
+		ui.Unlock()
+
+	case EXPORT_SCROBBLES:
+
+		ui.Lock()
+		exportScrobbles(request.exportFormat, request.exportFile)
+		ui.Unlock()
+
+	case COLUMNS_CHANGE:
+
+		saveColumns(request.columns)
+
+	case ALBUM_VIEW_TOGGLE:
+
+		config.Get().AlbumView = request.enable
+		if errSave := config.Save(); errSave != nil {
+			log.ErrorReport("update() ALBUM_VIEW_TOGGLE", "Could not save the configuration ("+errSave.Error()+").")
+		}
+
+	case REMAINING_TOGGLE:
+
+		config.Get().Progress.Remaining = request.enable
+		if errSave := config.Save(); errSave != nil {
+			log.ErrorReport("update() REMAINING_TOGGLE", "Could not save the configuration ("+errSave.Error()+").")
+		}
+
+	case AUTODJ_TOGGLE:
+
+		config.Get().AutoDJ.Enabled = request.enable
+		if errSave := config.Save(); errSave != nil {
+			log.ErrorReport("update() AUTODJ_TOGGLE", "Could not save the configuration ("+errSave.Error()+").")
+		}
+
+	default:
+		return false
+
+	} // end request switch
+
+	return true
+
+} // end localRequest
+
 // update blocks waiting for some other thread to tell it to force an update on the UI.
 // An update might come from:
 //	* A polling update from MPD
 //	* The user has interacted with juke in some way as to
 //	  force an update (button press, etc)
 // The incoming communication is an attempted state change or request
-// for general update.
-func update(stateRequestChannel chan *jukeRequest) {
+// for general update. update ends once done is closed; the request channel
+// itself is never closed, as goroutines of all kinds send on it.
+func update(stateRequestChannel chan *jukeRequest, done chan bool) {
 
 	var (
 		currentState  jukeState   = NOT_CONNECTED
@@ -132,8 +277,29 @@ func update(stateRequestChannel chan *jukeRequest) {
 		errDial       error       = nil
 		pollChannel   chan int    = make(chan int)
 		curPLVersion  int         = -1
+		listening     listenTracker
+		stickers      stickerSupport
+		dj            autoDJ
+		library       libraryCache
+		party         *partyMode = nil
+		mirror        queueMirror
+		group         roomGroup
+		outputs       outputWatcher
+		partition     string = ui.DEFAULT_PARTITION
+		settings      playbackSettings
+		history       queueHistory
 	)
 
+	// Songs that were listened to through are counted as played.
+	listening.onFinish = func(song mpd.Attrs, listened bool) {
+		if listened && !isStream(song["file"]) {
+			dj.listened(song["file"])
+			if currentState != NOT_CONNECTED {
+				stickers.played(mpdConnection, song["file"])
+			}
+		}
+	}
+
 	go func() {
 		// Juke needs to establish an initial connection.
 		// Thus, a thread is spawn just to send an initial CONNECTION_REFREASH.
@@ -141,19 +307,182 @@ func update(stateRequestChannel chan *jukeRequest) {
 		stateRequestChannel <- &jukeRequest{state: CONNECTION_REFREASH}
 	}()
 
-	for request := range stateRequestChannel {
+	for {
+
+		var request *jukeRequest
+		select {
+		case request = <-stateRequestChannel:
+		case <-done:
+		}
+		if request == nil {
+			break
+		}
+
+		if localRequest(request) {
+			continue
+		}
+
+		// A poll loop left over from an earlier connection is told to stop.
+		if request.state == POLL_REFREASH && request.pollReply != pollChannel {
+			request.pollReply <- END_POLLING
+			continue
+		}
+
+		// While the party has Juke's controls locked, nothing but the party
+		// (and the lock itself) may change the queue or playback.
+		if party != nil && config.Get().Party.LockControls && partyLocked(request.state) {
+			continue
+		}
+
+		if request.state == SWITCH_SERVER {
+			// Everything belonging to the old server is torn down before
+			// the new one is dialed, so that nothing from it lingers. The
+			// party's requests are queued on the old server, so it ends too.
+			if party != nil {
+				party.stop()
+				if currentState != NOT_CONNECTED {
+					party.restoreRandom(mpdConnection)
+				}
+				party = nil
+			}
+			if currentState != NOT_CONNECTED {
+				listening.finish()
+				outputs.stop()
+				currentState = NOT_CONNECTED
+				if errClose := mpdConnection.Close(); errClose != nil {
+					log.ErrorReport("update() SWITCH_SERVER", "Could not mpd.Close() ("+errClose.Error()+").")
+				}
+				// The running poll loop is now stale, see above.
+				pollChannel = make(chan int)
+			}
+			curPLVersion = -1
+			partition = ui.DEFAULT_PARTITION
+			ui.Lock()
+			ui.SetPartition(partition)
+			ui.SetPlayPause(false)
+			ui.SetCurrentSongNotConnected()
+			ui.SetCurrentAlbumArt(ui.NO_COVER_ARTWORK)
+			ui.SetProgressBarTimeStoppedOrDisconnected()
+			ui.ClearCurrentPlaylist()
+			ui.SetVolume(-1)
+			ui.SetPartyMode(false)
+			ui.SetControlsLocked(false)
+			ui.SetPartyRequests(nil)
+			history.forget()
+			ui.Unlock()
+			mirror.forget()
+			config.Get().Server = request.name
+			if errSave := config.Save(); errSave != nil {
+				log.ErrorReport("update() SWITCH_SERVER", "Could not save the configuration ("+errSave.Error()+").")
+			}
+			go func() {
+				stateRequestChannel <- &jukeRequest{state: CONNECTION_REFREASH}
+			}()
+			continue
+		}
+
+		if request.state == MIRROR_TOGGLE {
+			mirror.toggle(request.name, request.enable)
+			continue
+		}
+
+		if request.state == GROUP_TOGGLE {
+			if request.enable {
+				group.join(request.name)
+			} else {
+				group.leave(request.name)
+			}
+			ui.Lock()
+			ui.SetRoomStates(group.states())
+			ui.Unlock()
+			continue
+		}
+
+		// A global hotkey stands for the request of its control.
+		if request.state == HOTKEY_PRESS {
+			if request = hotkeyRequest(request.name); request == nil {
+				continue
+			}
+		}
+
+		// Party mode runs whether or not Juke is connected; guests simply
+		// find nothing while it is not.
+		switch request.state {
+		case PARTY_TOGGLE:
+			if request.enable && party == nil {
+				var errParty error
+				if party, errParty = startParty(stateRequestChannel); errParty != nil {
+					log.ErrorReport("update() PARTY_TOGGLE", "Could not start party mode ("+errParty.Error()+").")
+					ui.Lock()
+					ui.SetPartyMode(false)
+					ui.ShowMessage("Could not start party mode (" + errParty.Error() + ").")
+					ui.Unlock()
+				} else {
+					if currentState != NOT_CONNECTED {
+						party.setRandom(mpdConnection)
+					}
+					ui.Lock()
+					ui.SetControlsLocked(config.Get().Party.LockControls)
+					ui.Unlock()
+				}
+			} else if !request.enable && party != nil {
+				party.stop()
+				if currentState != NOT_CONNECTED {
+					party.restoreRandom(mpdConnection)
+				}
+				party = nil
+				ui.Lock()
+				ui.SetControlsLocked(false)
+				ui.SetPartyRequests(nil)
+				ui.Unlock()
+			}
+			continue
+		case PARTY_SEARCH, PARTY_LOOKUP:
+			if currentState == NOT_CONNECTED {
+				request.partyReply <- []partyResult{}
+				continue
+			}
+		case PARTY_SYNC, PARTY_DECIDE:
+			if party == nil {
+				continue
+			}
+			if request.state == PARTY_DECIDE {
+				party.decide(request.partyId, request.enable)
+			}
+		}
 
 		if currentState == NOT_CONNECTED {
 
 			if request.state == CONNECTION_REFREASH {
 				// If the user requests a connection and juke is unconnected, then juke
 				// attempts to reconnect.
-				mpdConnection, errDial = mpd.Dial("tcp", "127.0.0.1:6600")
+				server := config.Get().CurrentServer()
+				mpdConnection, errDial = dialServer(server)
 				if errDial != nil {
-					log.ErrorReport("update()", "Could not establish MPD connection ("+errDial.Error()+").")
+					log.ErrorReport("update()", "Could not establish MPD connection to "+server.Name+" ("+errDial.Error()+").")
 				} else {
 					// On successful connection, init polling and an unknown state.
-					go poll(stateRequestChannel, pollChannel)
+					stickers.detect(mpdConnection)
+					dj.reset()
+					library.reset()
+					outputs.watch(server, stateRequestChannel, done)
+					if party != nil {
+						party.forget()
+						party.setRandom(mpdConnection)
+					}
+					// A reconnection returns to the partition that was in use.
+					if partition != ui.DEFAULT_PARTITION {
+						if errPartition := switchPartition(mpdConnection, partition); errPartition != nil {
+							log.ErrorReport("update()", "Could not return to partition "+partition+" ("+errPartition.Error()+").")
+							partition = ui.DEFAULT_PARTITION
+						}
+					}
+					ui.Lock()
+					settings.refresh(mpdConnection)
+					ui.SetRatingsVisible(stickers.available)
+					ui.SetPartition(partition)
+					ui.Unlock()
+					go poll(stateRequestChannel, pollChannel, done)
 					// The real state is determined from first poll.
 					// All operations are now safe (most state requests have checks).
 					currentState = CONNECTED_AND_UNKNOWN
@@ -181,14 +510,23 @@ func update(stateRequestChannel chan *jukeRequest) {
 				ui.SetCurrentAlbumArt(ui.NO_COVER_ARTWORK)
 				ui.SetProgressBarTimeStoppedOrDisconnected()
 				ui.ClearCurrentPlaylist()
+				ui.SetVolume(-1)
 				currentState = NOT_CONNECTED
+				listening.finish()
+				outputs.stop()
 				pollChannel <- END_POLLING
 			} else if status["state"] == "stop" {
 				ui.SetPlayPause(false)
 				ui.SetCurrentSongStopped()
 				ui.SetCurrentAlbumArt(ui.NO_COVER_ARTWORK)
 				ui.SetProgressBarTimeStoppedOrDisconnected()
-				curPLVersion = updateSongList(mpdConnection, status, curPLVersion)
+				curPLVersion = updateSongList(mpdConnection, &stickers, status, curPLVersion)
+				ui.SetBitrate(0)
+				mirror.sync(mpdConnection, status)
+				ui.SetVolume(statusVolume(status))
+				ui.SetRoomStates(group.states())
+				settings.show(status)
+				listening.finish()
 				currentState = CONNECTED_AND_STOPPED
 				pollChannel <- STOPPED_POLLING
 			} else {
@@ -207,20 +545,35 @@ func update(stateRequestChannel chan *jukeRequest) {
 				if curSong, errCurSong := mpdConnection.CurrentSong(); errCurSong != nil {
 					log.ErrorReport("update() POLL_REFREASH", "Could not establish MPD current song ("+errCurSong.Error()+").")
 				} else {
-					ui.SetCurrentSong(curSong["Title"], curSong["Artist"], curSong["Album"])
-					ui.SetCurrentAlbumArt(albumArtFilename(curSong["file"]))
-					totalTime, errTotalTime := strconv.Atoi(curSong["Time"])
-					curTime, errCurTime := strconv.Atoi(strings.SplitN(status["time"], ":", 2)[0])
+					showCurrentSong(curSong)
+					if currentState == CONNECTED_AND_PLAYING {
+						dj.check(mpdConnection, &stickers, &library, status, curSong)
+					}
+					totalTime, errTotalTime := songDuration(status, curSong)
+					curTime, errCurTime := strconv.ParseFloat(status["elapsed"], 64)
 					if errTotalTime != nil {
 						log.ErrorReport("update() POLL_REFREASH", "Could not convert current song total time ("+errTotalTime.Error()+").")
 					} else if errCurTime != nil {
 						log.ErrorReport("update() POLL_REFREASH", "Could not convert current song time ("+errCurTime.Error()+").")
 					} else {
 						ui.SetProgressBarTime(curTime, totalTime)
+						listening.observe(curSong, int(curTime), int(totalTime))
 					}
 				}
 
-				curPLVersion = updateSongList(mpdConnection, status, curPLVersion)
+				curPLVersion = updateSongList(mpdConnection, &stickers, status, curPLVersion)
+				// MPD only knows the bitrate of the song it is playing.
+				bitrate, _ := strconv.Atoi(status["bitrate"])
+				ui.SetBitrate(bitrate)
+				mirror.sync(mpdConnection, status)
+				ui.SetVolume(statusVolume(status))
+				ui.SetRoomStates(group.states())
+				settings.show(status)
+
+				// A new song may be a request that has now been honoured.
+				if party != nil && status["songid"] != party.playing {
+					party.apply(mpdConnection, status)
+				}
 
 			} // end status state conditional
 
@@ -234,22 +587,17 @@ func update(stateRequestChannel chan *jukeRequest) {
 
 		case SORT_PLAYLIST:
 
-			cmdList := mpdConnection.BeginCommandList()
-
-			pos := 0
-			for row := range request.playlistChan {
-				cmdList.MoveId(row.ID, pos)
-				pos++
-			}
-
-			if cmdErr := cmdList.End(); cmdErr != nil {
-				log.ErrorReport("update() SORT_PLAYLIST", "Could not end the command list ("+cmdErr.Error()+").")
+			history.record(mpdConnection)
+			if order, version, errSort := sortQueue(mpdConnection, &stickers, request.sortKeys, request.descending); errSort != nil {
+				log.ErrorReport("update() SORT_PLAYLIST", "Could not sort the current playlist ("+errSort.Error()+").")
 			} else {
-				curPLVersion += pos
+				ui.ReorderCurrentPlaylist(order)
+				curPLVersion = version
 			}
 
 		case REMOVE_PLAYLIST:
 
+			history.record(mpdConnection)
 			cmdList := mpdConnection.BeginCommandList()
 
 			rmRows := 0
@@ -269,9 +617,94 @@ func update(stateRequestChannel chan *jukeRequest) {
 
 		case CLEAR_PLAYLIST:
 
+			history.record(mpdConnection)
 			mpdConnection.Clear()
 			ui.ClearCurrentPlaylist()
 
+		case ADD_SONGS:
+
+			cmdList := mpdConnection.BeginCommandList()
+			for _, uri := range request.uris {
+				cmdList.Add(uri)
+			}
+			if cmdErr := cmdList.End(); cmdErr != nil {
+				log.ErrorReport("update() ADD_SONGS", "Could not end the command list ("+cmdErr.Error()+").")
+			}
+
+		case RATE_SONG:
+
+			if stickers.setRating(mpdConnection, request.clickedRow.File, request.rating) {
+				ui.SetRowRating(request.clickedRow, request.rating)
+			} else {
+				ui.SetRatingsVisible(stickers.available)
+			}
+
+		case RATE_CURRENT:
+
+			if curSong, errCurSong := mpdConnection.CurrentSong(); errCurSong != nil {
+				log.ErrorReport("update() RATE_CURRENT", "Could not establish current song ("+errCurSong.Error()+").")
+			} else if curSong["file"] != "" {
+				if stickers.setRating(mpdConnection, curSong["file"], request.rating) {
+					ui.SetCurrentRating(request.rating)
+				} else {
+					ui.SetRatingsVisible(stickers.available)
+				}
+			}
+
+		case SMART_PLAYLIST:
+
+			materializeSmartPlaylist(mpdConnection, &stickers, &library, request.name, request.save)
+
+		case SEND_PLAYBACK:
+
+			if errSend := sendPlayback(mpdConnection, stateRequestChannel, done, request.name, request.save); errSend != nil {
+				log.ErrorReport("update() SEND_PLAYBACK", "Could not send playback to "+request.name+" ("+errSend.Error()+").")
+				ui.ShowMessage("Could not send playback to " + request.name + " (" + errSend.Error() + ").")
+			}
+
+		case PLAYBACK_SENT:
+
+			if request.err != nil {
+				log.ErrorReport("update() PLAYBACK_SENT", "Could not send playback to "+request.name+" ("+request.err.Error()+").")
+				ui.ShowMessage("Could not send playback to " + request.name + " (" + request.err.Error() + ").")
+			} else if request.save {
+				if errStop := mpdConnection.Stop(); errStop != nil {
+					log.ErrorReport("update() PLAYBACK_SENT", "Could not mpd.Stop() ("+errStop.Error()+").")
+				} else {
+					ui.SetPlayPause(false)
+					ui.SetCurrentSongStopped()
+					ui.SetCurrentAlbumArt(ui.NO_COVER_ARTWORK)
+					ui.SetProgressBarTimeStoppedOrDisconnected()
+					listening.finish()
+					currentState = CONNECTED_AND_STOPPED
+				}
+			}
+
+		case PARTY_SEARCH:
+
+			request.partyReply <- searchLibrary(mpdConnection, request.name)
+
+		case PARTY_LOOKUP:
+
+			request.partyReply <- lookupLibrary(mpdConnection, request.name)
+
+		case PALETTE_SEARCH:
+
+			found := searchLibrary(mpdConnection, request.name)
+			songs := make([]ui.PaletteSong, len(found))
+			for i, song := range found {
+				songs[i] = ui.PaletteSong{File: song.URI, Title: song.Title, Artist: song.Artist, Album: song.Album}
+			}
+			ui.SetPaletteSongs(request.name, songs)
+
+		case PARTY_SYNC, PARTY_DECIDE:
+
+			if status, errStatus := mpdConnection.Status(); errStatus != nil {
+				log.ErrorReport("update() PARTY_SYNC", "Could not establish MPD status ("+errStatus.Error()+").")
+			} else {
+				party.apply(mpdConnection, status)
+			}
+
 		case NEXT_TRACK, PREVIOUS_TRACK:
 
 			if currentState > CONNECTED_AND_STOPPED {
@@ -279,18 +712,23 @@ func update(stateRequestChannel chan *jukeRequest) {
 					if errPrev := mpdConnection.Previous(); errPrev != nil {
 						log.ErrorReport("update() PREVIOUS_TRACK", "Could not mpd.Previous() ("+errPrev.Error()+").")
 					}
+					group.playback(GROUP_PREVIOUS)
 				} else { // NEXT_TRACK
+					// Moving on before the song counted as listened is a skip.
+					if listening.songId != "" && !listening.recorded {
+						stickers.skipped(mpdConnection, listening.song["file"])
+					}
 					if errNext := mpdConnection.Next(); errNext != nil {
 						log.ErrorReport("update() NEXT_TRACK", "Could not mpd.Next() ("+errNext.Error()+").")
 					}
+					group.playback(GROUP_NEXT)
 				}
 
 				if curSong, errCurSong := mpdConnection.CurrentSong(); errCurSong != nil {
 					log.ErrorReport("update() NEXT/PREV_TRACK", "Could not establish current song ("+errCurSong.Error()+").")
 				} else {
-					ui.SetCurrentSong(curSong["Title"], curSong["Artist"], curSong["Album"])
-					ui.SetCurrentAlbumArt(albumArtFilename(curSong["file"]))
-					if totalTime, errTotalTime := strconv.Atoi(curSong["Time"]); errTotalTime != nil {
+					showCurrentSong(curSong)
+					if totalTime, errTotalTime := songDuration(nil, curSong); errTotalTime != nil {
 						log.ErrorReport("update() NEXT/PREV_TRACK", "Could not convert current song total time ("+errTotalTime.Error()+").")
 					} else {
 						ui.SetProgressBarTime(0, totalTime)
@@ -306,6 +744,7 @@ func update(stateRequestChannel chan *jukeRequest) {
 				} else {
 					ui.SetPlayPause(false)
 					currentState = CONNECTED_AND_PAUSED
+					group.playback(GROUP_PAUSE)
 				}
 			} else if currentState == CONNECTED_AND_PAUSED {
 				if errPause := mpdConnection.Pause(false); errPause != nil {
@@ -313,6 +752,7 @@ func update(stateRequestChannel chan *jukeRequest) {
 				} else {
 					ui.SetPlayPause(true)
 					currentState = CONNECTED_AND_PLAYING
+					group.playback(GROUP_PLAY)
 				}
 			} else if currentState == CONNECTED_AND_STOPPED {
 				if errReplay := mpdConnection.PlayId(-1); errReplay != nil {
@@ -321,13 +761,13 @@ func update(stateRequestChannel chan *jukeRequest) {
 
 					ui.SetPlayPause(true)
 					currentState = CONNECTED_AND_PLAYING
+					group.playback(GROUP_PLAY)
 
 					if curSong, errCurSong := mpdConnection.CurrentSong(); errCurSong != nil {
 						log.ErrorReport("update() PLAY_OR_PAUSE", "Could not establish current song ("+errCurSong.Error()+").")
 					} else {
-						ui.SetCurrentSong(curSong["Title"], curSong["Artist"], curSong["Album"])
-						ui.SetCurrentAlbumArt(albumArtFilename(curSong["file"]))
-						if totalTime, errTotalTime := strconv.Atoi(curSong["Time"]); errTotalTime != nil {
+						showCurrentSong(curSong)
+						if totalTime, errTotalTime := songDuration(nil, curSong); errTotalTime != nil {
 							log.ErrorReport("update() PLAY_OR_PAUSE", "Could not convert current song total time ("+errTotalTime.Error()+").")
 						} else {
 							ui.SetProgressBarTime(0, totalTime)
@@ -346,7 +786,125 @@ func update(stateRequestChannel chan *jukeRequest) {
 				ui.SetCurrentSongStopped()
 				ui.SetCurrentAlbumArt(ui.NO_COVER_ARTWORK)
 				ui.SetProgressBarTimeStoppedOrDisconnected()
+				listening.finish()
 				currentState = CONNECTED_AND_STOPPED
+				group.playback(GROUP_STOP)
+			}
+
+		case OUTPUTS_REFRESH:
+
+			ui.SetOutputs(listOutputs(mpdConnection, partition))
+			showPartitions(mpdConnection, partition)
+
+		case OUTPUT_CHANGE:
+
+			if errOutput := changeOutput(mpdConnection, request.outputId, request.outputAction); errOutput != nil {
+				log.ErrorReport("update() OUTPUT_CHANGE", "Could not change output "+strconv.Itoa(request.outputId)+" ("+errOutput.Error()+").")
+			}
+			ui.SetOutputs(listOutputs(mpdConnection, partition))
+
+		case OUTPUT_MOVE:
+
+			if errMove := moveOutput(request.name, request.partition); errMove != nil {
+				log.ErrorReport("update() OUTPUT_MOVE", "Could not move output "+request.name+" ("+errMove.Error()+").")
+				ui.ShowMessage("Could not move output " + request.name + " (" + errMove.Error() + ").")
+			}
+			ui.SetOutputs(listOutputs(mpdConnection, partition))
+
+		case PARTITIONS_REFRESH:
+
+			showPartitions(mpdConnection, partition)
+
+		case PARTITION_SWITCH:
+
+			if errPartition := switchPartition(mpdConnection, request.partition); errPartition != nil {
+				log.ErrorReport("update() PARTITION_SWITCH", "Could not switch to partition "+request.partition+" ("+errPartition.Error()+").")
+				ui.ShowMessage("Could not switch to partition " + request.partition + " (" + errPartition.Error() + ").")
+			} else {
+				// The queue and player are the new partition's from here on,
+				// the next poll fills them in.
+				listening.finish()
+				partition = request.partition
+				curPLVersion = -1
+				currentState = CONNECTED_AND_UNKNOWN
+				ui.ClearCurrentPlaylist()
+				history.forget()
+				ui.SetPartition(partition)
+				showPartitions(mpdConnection, partition)
+				ui.SetOutputs(listOutputs(mpdConnection, partition))
+				settings.refresh(mpdConnection)
+			}
+
+		case PARTITION_NEW:
+
+			if errNew := mpdConnection.Command("newpartition %s", request.partition).OK(); errNew != nil {
+				log.ErrorReport("update() PARTITION_NEW", "Could not create partition "+request.partition+" ("+errNew.Error()+").")
+				ui.ShowMessage("Could not create partition " + request.partition + " (" + errNew.Error() + ").")
+			}
+			showPartitions(mpdConnection, partition)
+
+		case PARTITION_DELETE:
+
+			if errDelete := mpdConnection.Command("delpartition %s", request.partition).OK(); errDelete != nil {
+				log.ErrorReport("update() PARTITION_DELETE", "Could not delete partition "+request.partition+" ("+errDelete.Error()+").")
+				ui.ShowMessage("Could not delete partition " + request.partition + " (" + errDelete.Error() + ").")
+			}
+			showPartitions(mpdConnection, partition)
+
+		case SETTINGS_REFRESH:
+
+			settings.refresh(mpdConnection)
+			if status, errStatus := mpdConnection.Status(); errStatus != nil {
+				log.ErrorReport("update() SETTINGS_REFRESH", "Could not establish MPD status ("+errStatus.Error()+").")
+			} else {
+				settings.show(status)
+			}
+
+		case SETTING_CHANGE:
+
+			if errSetting := settings.change(mpdConnection, request.setting, request.value); errSetting != nil {
+				log.ErrorReport("update() SETTING_CHANGE", "Could not change the playback settings ("+errSetting.Error()+").")
+			}
+
+		case QUEUE_OPERATION:
+
+			history.record(mpdConnection)
+			if errQueue := queueOperation(mpdConnection, request.queueOp, request.priority, request.ids); errQueue != nil {
+				log.ErrorReport("update() QUEUE_OPERATION", "Could not change the current playlist ("+errQueue.Error()+").")
+			}
+
+		case UNDO_QUEUE:
+
+			if errUndo := history.step(mpdConnection, request.redo); errUndo != nil {
+				log.ErrorReport("update() UNDO_QUEUE", "Could not restore the current playlist ("+errUndo.Error()+").")
+			}
+			// The songs have new IDs, the next poll reloads them all.
+			curPLVersion = -1
+
+		case VOLUME_CHANGE:
+
+			if errVolume := mpdConnection.SetVolume(request.volume); errVolume != nil {
+				log.ErrorReport("update() VOLUME_CHANGE", "Could not mpd.SetVolume() ("+errVolume.Error()+").")
+			}
+			group.setVolume(request.volume)
+
+		case VOLUME_STEP:
+
+			if status, errStatus := mpdConnection.Status(); errStatus != nil {
+				log.ErrorReport("update() VOLUME_STEP", "Could not establish MPD status ("+errStatus.Error()+").")
+			} else if volume := statusVolume(status); volume >= 0 {
+				volume += request.volume
+				if volume < 0 {
+					volume = 0
+				} else if volume > 100 {
+					volume = 100
+				}
+				if errVolume := mpdConnection.SetVolume(volume); errVolume != nil {
+					log.ErrorReport("update() VOLUME_STEP", "Could not mpd.SetVolume() ("+errVolume.Error()+").")
+				} else {
+					ui.SetVolume(volume)
+				}
+				group.setVolume(volume)
 			}
 
 		case PROGRESS_CHANGE:
@@ -356,16 +914,12 @@ func update(stateRequestChannel chan *jukeRequest) {
 				if status, errStatus := mpdConnection.Status(); errStatus != nil {
 					log.ErrorReport("update() PROGRESS_CHANGE", "Could not establish MPD status ("+errStatus.Error()+").")
 				} else {
-					song, intErr1 := strconv.Atoi(status["song"])
-					length, intErr2 := strconv.Atoi(strings.SplitN(status["time"], ":", 2)[1])
-					if intErr1 != nil {
-						log.ErrorReport("update() PROGRESS_CHANGE", "Could not convert song ("+intErr1.Error()+").")
-					} else if intErr2 != nil {
-						log.ErrorReport("update() PROGRESS_CHANGE", "Could not convert length ("+intErr2.Error()+").")
-					} else {
-						seektime := int(float64(request.progressX) / float64(request.progressWidth) * float64(length))
-						if seekErr := mpdConnection.Seek(song, seektime); seekErr != nil {
-							log.ErrorReport("update() PROGRESS_CHANGE", "Could not mpd.Seek() ("+seekErr.Error()+").")
+					if length, errLength := songDuration(status, nil); errLength != nil {
+						log.ErrorReport("update() PROGRESS_CHANGE", "Could not convert length ("+errLength.Error()+").")
+					} else if length > 0 {
+						seektime := float64(request.progressX) / float64(request.progressWidth) * length
+						if seekErr := mpdConnection.SeekCur(time.Duration(seektime*float64(time.Second)), false); seekErr != nil {
+							log.ErrorReport("update() PROGRESS_CHANGE", "Could not mpd.SeekCur() ("+seekErr.Error()+").")
 						} else {
 							ui.SetProgressBarTime(seektime, length)
 						}
@@ -374,13 +928,36 @@ func update(stateRequestChannel chan *jukeRequest) {
 
 			} // end is not stopped
 
+		case SEEK_RELATIVE:
+
+			if currentState > CONNECTED_AND_STOPPED {
+				if seekErr := mpdConnection.SeekCur(time.Duration(request.seek)*time.Second, true); seekErr != nil {
+					log.ErrorReport("update() SEEK_RELATIVE", "Could not mpd.SeekCur() ("+seekErr.Error()+").")
+				} else if status, errStatus := mpdConnection.Status(); errStatus != nil {
+					log.ErrorReport("update() SEEK_RELATIVE", "Could not establish MPD status ("+errStatus.Error()+").")
+				} else {
+					// Show where the song is now, rather than wait for the next poll.
+					elapsed, errElapsed := strconv.ParseFloat(status["elapsed"], 64)
+					duration, errDuration := songDuration(status, nil)
+					if errElapsed == nil && errDuration == nil {
+						ui.SetProgressBarTime(elapsed, duration)
+					}
+				}
+			}
+
 		} // end request switch
 
 		ui.Unlock()
 
 	} // end for wait on channel
 
-	pollChannel <- END_POLLING
+	// The poll loop sees done for itself.
+	group.leaveAll()
+	outputs.stop()
+	if currentState > NOT_CONNECTED {
+		// The song playing now is kept in the history if it was listened to.
+		listening.finish()
+	}
 
 	// Close the MPD connection, Juke is about to end:
 	if currentState > NOT_CONNECTED {
@@ -391,13 +968,18 @@ func update(stateRequestChannel chan *jukeRequest) {
 
 } // end update
 
-// poll is used to send signals every so often.
-func poll(updateChannel chan *jukeRequest, pollChannel chan int) {
+// poll is used to send signals every so often, until told to stop or done
+// is closed.
+func poll(updateChannel chan *jukeRequest, pollChannel chan int, done chan bool) {
 
 	var rate int
 
 	for {
-		updateChannel <- &jukeRequest{state: POLL_REFREASH}
+		select {
+		case updateChannel <- &jukeRequest{state: POLL_REFREASH, pollReply: pollChannel}:
+		case <-done:
+			return
+		}
 
 		rate = <-pollChannel
 		if rate == END_POLLING {
@@ -0,0 +1,366 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has multi-room group control: other server profiles
+that follow the playback buttons and volume of the one in use.
+*/
+
+package main
+
+import (
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/config"
+	"github.com/idealeric/juke/log"
+	"github.com/idealeric/juke/ui"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Playback actions applied to a group:
+const (
+	GROUP_PLAY = iota
+	GROUP_PAUSE
+	GROUP_STOP
+	GROUP_NEXT
+	GROUP_PREVIOUS
+)
+
+// Timing of the group's goroutine:
+const (
+	GROUP_POLL_INTERVAL  = 2 * time.Second  // how often the members' states are polled
+	GROUP_RETRY_MIN      = 2 * time.Second  // how soon an unreachable member is first redialed...
+	GROUP_RETRY_INTERVAL = 30 * time.Second // ...doubling each time it stays unreachable, up to this
+	GROUP_DIAL_TIMEOUT   = 3 * time.Second  // how long a member may take to answer a dial
+	GROUP_NO_VOLUME      = -1               // no volume waiting to be set
+)
+
+// roomGroup holds a connection to every member of the group. Members are
+// kept connected (polling their state keeps MPD from timing them out) and
+// a member whose connection has dropped is redialed on its next use, or
+// later still while it keeps failing to answer. The profile in use is
+// never acted on as a member, it is already in hand.
+//
+// The members are only touched by the group's own goroutine, which polls
+// their states and runs what update() asks of them, so that a slow or
+// unreachable member never holds update() (or the UI) up. update() never
+// waits on the goroutine either: it leaves its requests under the lock and
+// wakes the goroutine. Joins and leaves are run in order, but only the
+// latest playback action and volume are kept (a dragged volume slider
+// asks for many), the ones before them being out of date.
+type roomGroup struct {
+	wake     chan bool                // wakes the group's goroutine (never blocks)
+	done     chan bool                // closed once the goroutine has ended
+	lock     sync.Mutex               // guards polled and the requests below
+	polled   []*ui.RoomState          // the members' states as last polled
+	changes  []func()                 // joins and leaves waiting, in order
+	action   int                      // playback action waiting...
+	acting   bool                     // ...if any
+	volume   int                      // volume waiting, GROUP_NO_VOLUME if none
+	current  string                   // name of the profile in use
+	quit     bool                     // Juke is about to end
+	members  map[string]*mpd.Client   // nil while a member is unreachable (group's goroutine only)
+	servers  map[string]config.Server // profile of each member (group's goroutine only)
+	dialed   map[string]time.Time     // when each member was last dialed (group's goroutine only)
+	failures map[string]int           // dials failed in a row, by member (group's goroutine only)
+}
+
+// request leaves a request for the group's goroutine (starting it if need
+// be) and wakes it. Called from update().
+func (group *roomGroup) request(ask func()) {
+
+	if group.wake == nil {
+		group.wake = make(chan bool, 1)
+		group.done = make(chan bool)
+		group.volume = GROUP_NO_VOLUME
+		group.members = make(map[string]*mpd.Client)
+		group.servers = make(map[string]config.Server)
+		group.dialed = make(map[string]time.Time)
+		group.failures = make(map[string]int)
+		go group.run()
+	}
+
+	group.lock.Lock()
+	group.current = config.Get().CurrentServer().Name
+	ask()
+	group.lock.Unlock()
+
+	select {
+	case group.wake <- true:
+	default: // already woken
+	}
+
+} // end request
+
+// run is the group's goroutine: it runs what it is asked when woken, and
+// polls the members now and then.
+func (group *roomGroup) run() {
+
+	ticker := time.NewTicker(GROUP_POLL_INTERVAL)
+	defer ticker.Stop()
+	defer close(group.done)
+	current := ""
+	for {
+		select {
+		case <-group.wake:
+			group.lock.Lock()
+			changes, action, acting, volume, quit := group.changes, group.action, group.acting, group.volume, group.quit
+			group.changes, group.acting, group.volume = nil, false, GROUP_NO_VOLUME
+			current = group.current
+			group.lock.Unlock()
+
+			if quit {
+				for name := range group.members {
+					group.disconnect(name)
+				}
+				return
+			}
+			for _, change := range changes {
+				change()
+			}
+			if acting {
+				group.each(current, "roomGroup.playback()", func(conn *mpd.Client) error {
+					return groupPlayback(conn, action)
+				})
+			}
+			if volume != GROUP_NO_VOLUME {
+				group.each(current, "roomGroup.setVolume()", func(conn *mpd.Client) error {
+					return conn.SetVolume(volume)
+				})
+			}
+			group.poll(current)
+		case <-ticker.C:
+			group.poll(current)
+		}
+	}
+
+} // end run
+
+// join adds a profile to the group. Called from update().
+func (group *roomGroup) join(name string) {
+
+	server := findServer(name)
+	if server == nil {
+		log.ErrorReport("roomGroup.join()", "No server named "+name+".")
+		return
+	}
+	profile := *server
+	group.request(func() {
+		group.changes = append(group.changes, func() {
+			if _, exists := group.members[name]; !exists {
+				group.members[name] = nil
+				group.servers[name] = profile
+				group.connect(name)
+			}
+		})
+	})
+
+} // end join
+
+// leave removes a profile from the group. Called from update().
+func (group *roomGroup) leave(name string) {
+
+	group.request(func() {
+		group.changes = append(group.changes, func() {
+			group.disconnect(name)
+		})
+	})
+
+} // end leave
+
+// disconnect closes a member's connection and forgets the member.
+func (group *roomGroup) disconnect(name string) {
+
+	if conn := group.members[name]; conn != nil {
+		if errClose := conn.Close(); errClose != nil {
+			log.ErrorReport("roomGroup.disconnect()", "Could not mpd.Close() ("+errClose.Error()+").")
+		}
+	}
+	delete(group.members, name)
+	delete(group.servers, name)
+	delete(group.dialed, name)
+	delete(group.failures, name)
+
+} // end disconnect
+
+// connect (re)dials a member, returning nil if it cannot be reached.
+func (group *roomGroup) connect(name string) *mpd.Client {
+
+	server := group.servers[name]
+	group.dialed[name] = time.Now()
+	conn, errDial := dialServerTimeout(&server, GROUP_DIAL_TIMEOUT)
+	if errDial != nil {
+		group.failures[name]++
+		log.ErrorReport("roomGroup.connect()", "Could not establish MPD connection to "+name+" ("+errDial.Error()+").")
+		return nil
+	}
+	group.failures[name] = 0
+	group.members[name] = conn
+	return conn
+
+} // end connect
+
+// due tells whether an unreachable member may be redialed yet: the more
+// dials of it have failed in a row, the longer it is left alone.
+func (group *roomGroup) due(name string) bool {
+
+	wait := GROUP_RETRY_MIN
+	for i := 1; i < group.failures[name] && wait < GROUP_RETRY_INTERVAL; i++ {
+		wait *= 2
+	}
+	if wait > GROUP_RETRY_INTERVAL {
+		wait = GROUP_RETRY_INTERVAL
+	}
+	return group.failures[name] == 0 || time.Since(group.dialed[name]) >= wait
+
+} // end due
+
+// names returns the members other than the profile in use, in order.
+func (group *roomGroup) names(current string) []string {
+
+	var names []string
+	for name := range group.members {
+		if name != current {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+
+} // end names
+
+// each runs a command on every member. A member whose command fails is
+// redialed and the command tried once more. An unreachable member is only
+// redialed when due, and is otherwise left out.
+func (group *roomGroup) each(current, where string, command func(*mpd.Client) error) {
+
+	for _, name := range group.names(current) {
+		conn := group.members[name]
+		if conn == nil {
+			if !group.due(name) {
+				continue
+			}
+			if conn = group.connect(name); conn == nil {
+				continue
+			}
+		}
+		if errCommand := command(conn); errCommand != nil {
+			conn.Close()
+			group.members[name] = nil
+			if conn = group.connect(name); conn == nil {
+				continue
+			}
+			if errRetry := command(conn); errRetry != nil {
+				log.ErrorReport(where, "Command failed on "+name+" ("+errRetry.Error()+").")
+			}
+		}
+	}
+
+} // end each
+
+// groupPlayback applies a playback action to a member.
+func groupPlayback(conn *mpd.Client, action int) error {
+
+	switch action {
+	case GROUP_PLAY:
+		// Play resumes a paused member and starts a stopped one.
+		return conn.Play(-1)
+	case GROUP_PAUSE:
+		return conn.Pause(true)
+	case GROUP_STOP:
+		return conn.Stop()
+	case GROUP_NEXT:
+		return conn.Next()
+	case GROUP_PREVIOUS:
+		return conn.Previous()
+	}
+	return nil
+
+} // end groupPlayback
+
+// playback applies a playback action to every member. Called from
+// update(), an action still waiting is replaced.
+func (group *roomGroup) playback(action int) {
+
+	group.request(func() {
+		group.action, group.acting = action, true
+	})
+
+} // end playback
+
+// setVolume sets the volume of every member. Called from update(), a
+// volume still waiting is replaced.
+func (group *roomGroup) setVolume(volume int) {
+
+	group.request(func() {
+		group.volume = volume
+	})
+
+} // end setVolume
+
+// poll finds out what every member is doing, for states().
+func (group *roomGroup) poll(current string) {
+
+	var states []*ui.RoomState
+	for _, name := range group.names(current) {
+		state := &ui.RoomState{Name: name, State: "unreachable", Volume: -1}
+		if conn := group.members[name]; conn != nil {
+			if status, errStatus := conn.Status(); errStatus != nil {
+				conn.Close()
+				group.members[name] = nil
+			} else {
+				state.State = status["state"]
+				state.Volume = statusVolume(status)
+			}
+		} else if group.due(name) {
+			// An unreachable member is only redialed now and then.
+			group.connect(name)
+		}
+		states = append(states, state)
+	}
+
+	group.lock.Lock()
+	group.polled = states
+	group.lock.Unlock()
+
+} // end poll
+
+// states describes what every member was doing when last polled, for the
+// UI.
+func (group *roomGroup) states() []*ui.RoomState {
+
+	group.lock.Lock()
+	defer group.lock.Unlock()
+	return group.polled
+
+} // end states
+
+// statusVolume returns the volume in a status (-1 if there is no mixer).
+func statusVolume(status mpd.Attrs) int {
+
+	volume, errVolume := strconv.Atoi(status["volume"])
+	if errVolume != nil {
+		return -1
+	}
+	return volume
+
+} // end statusVolume
+
+// leaveAll closes every member's connection and ends the group's
+// goroutine, Juke is about to end. It waits (a while) for the goroutine to
+// be done.
+func (group *roomGroup) leaveAll() {
+
+	if group.wake == nil {
+		return
+	}
+	group.request(func() {
+		group.quit = true
+	})
+	select {
+	case <-group.done:
+	case <-time.After(GROUP_DIAL_TIMEOUT):
+	}
+
+} // end leaveAll
@@ -7,17 +7,28 @@ This particular file has Juke's helper functions (small operations).
 package main
 
 import (
+	"fmt"
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/config"
 	"github.com/idealeric/juke/log"
 	"github.com/idealeric/juke/ui"
 	"os"
 	"os/user"
 	"path"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // albumArtFilename takes a subdirectory of a song and attempts to string
 // it together with a music directory and proper filename.
 func albumArtFilename(subDir string) string {
 
+	// Streams have no directory (nor artwork) of their own.
+	if isStream(subDir) {
+		return ui.NO_COVER_ARTWORK
+	}
+
 	// TODO - enable music directory to be configured
 	usr, err := user.Current()
 	if err != nil || subDir == "" {
@@ -40,3 +51,193 @@ func albumArtFilename(subDir string) string {
 	return ui.NO_COVER_ARTWORK
 
 } // end albumArtFilename
+
+// jukeDataFilename returns the full path of a file in Juke's data
+// directory (~/.juke). An empty string is returned if the directory
+// can not be established.
+func jukeDataFilename(name string) string {
+
+	dataDir, err := config.DataDir()
+	if err != nil {
+		log.ErrorReport("jukeDataFilename()", "Could not establish the data directory ("+err.Error()+").")
+		return ""
+	}
+
+	return path.Join(dataDir, name)
+
+} // end jukeDataFilename
+
+// dialServer connects to an MPD server profile.
+func dialServer(server *config.Server) (*mpd.Client, error) {
+
+	network := "tcp"
+	if strings.HasPrefix(server.Address, "/") {
+		network = "unix"
+	}
+	if server.Password != "" {
+		return mpd.DialAuthenticated(network, server.Address, server.Password)
+	}
+	return mpd.Dial(network, server.Address)
+
+} // end dialServer
+
+// dialServerTimeout connects to an MPD server profile, giving up on a
+// server that has not answered (and greeted, and taken the password) in
+// time. The MPD library dials without a timeout, so the dial is left to
+// finish on its own; a connection that comes too late is closed.
+func dialServerTimeout(server *config.Server, timeout time.Duration) (*mpd.Client, error) {
+
+	type dialed struct {
+		client *mpd.Client
+		err    error
+	}
+	result := make(chan dialed, 1)
+	go func() {
+		client, errDial := dialServer(server)
+		result <- dialed{client, errDial}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case answer := <-result:
+		return answer.client, answer.err
+	case <-timer.C:
+		go func() {
+			if late := <-result; late.err == nil {
+				late.client.Close()
+			}
+		}()
+		return nil, fmt.Errorf("%s did not answer within %v", server.Address, timeout)
+	}
+
+} // end dialServerTimeout
+
+// columnLayout turns the configured columns into the UI's layout.
+func columnLayout(columns []config.Column) []ui.ColumnSetting {
+
+	layout := make([]ui.ColumnSetting, len(columns))
+	for i, column := range columns {
+		layout[i] = ui.ColumnSetting{Name: column.Name, Visible: column.Visible, Width: column.Width}
+	}
+	return layout
+
+} // end columnLayout
+
+// saveColumns keeps the UI's column layout in the configuration.
+func saveColumns(layout []ui.ColumnSetting) {
+
+	columns := make([]config.Column, len(layout))
+	for i, setting := range layout {
+		columns[i] = config.Column{Name: setting.Name, Visible: setting.Visible, Width: setting.Width}
+	}
+	config.Get().Columns = columns
+	if errSave := config.Save(); errSave != nil {
+		log.ErrorReport("saveColumns()", "Could not save the configuration ("+errSave.Error()+").")
+	}
+
+} // end saveColumns
+
+// isStream tells whether a file is a stream (internet radio and the like)
+// rather than a song of the library.
+func isStream(file string) bool {
+
+	return strings.Contains(file, "://")
+
+} // end isStream
+
+// songName returns the name a song of the current playlist is shown by.
+// For a stream, that is the name of the station if it has one.
+func songName(song mpd.Attrs) string {
+
+	if !isStream(song["file"]) {
+		return song["Title"]
+	}
+	if song["Name"] != "" {
+		return song["Name"]
+	}
+	if song["Title"] != "" {
+		return song["Title"]
+	}
+	return song["file"]
+
+} // end songName
+
+// showCurrentSong shows the current song (or stream) and its artwork.
+func showCurrentSong(song mpd.Attrs) {
+
+	if isStream(song["file"]) {
+		station := song["Name"]
+		if station == "" {
+			station = song["file"]
+		}
+		ui.SetCurrentStream(station, song["Title"])
+	} else {
+		ui.SetCurrentSong(song["Title"], song["Artist"], song["Album"])
+	}
+	ui.SetCurrentAlbumArt(albumArtFilename(song["file"]))
+
+} // end showCurrentSong
+
+// songDuration returns the length (in seconds) of a song, from the most
+// precise field MPD gives for it. The status may be nil. A song of no
+// known length (a stream) is 0 seconds long.
+func songDuration(status, song mpd.Attrs) (float64, error) {
+
+	if duration, found := status["duration"]; found {
+		return strconv.ParseFloat(duration, 64)
+	}
+	if duration, found := song["duration"]; found {
+		return strconv.ParseFloat(duration, 64)
+	}
+	if times := strings.SplitN(status["time"], ":", 2); len(times) == 2 {
+		return strconv.ParseFloat(times[1], 64)
+	}
+	if duration, found := song["Time"]; found {
+		return strconv.ParseFloat(duration, 64)
+	}
+	return 0, nil
+
+} // end songDuration
+
+// libraryCache keeps every song of the library, so that the whole of it is
+// only listed again once MPD's database has changed.
+type libraryCache struct {
+	songs   []mpd.Attrs
+	version string // db_update of the listing
+}
+
+// reset forgets the library, for use on a new connection.
+func (library *libraryCache) reset() {
+
+	library.songs = nil
+	library.version = ""
+
+} // end reset
+
+// refresh lists the library again if MPD's database has changed.
+func (library *libraryCache) refresh(mpdConnection *mpd.Client) error {
+
+	stats, errStats := mpdConnection.Stats()
+	if errStats != nil {
+		return errStats
+	}
+	if library.songs != nil && stats["db_update"] == library.version {
+		return nil
+	}
+
+	listing, errListing := mpdConnection.ListAllInfo("/")
+	if errListing != nil {
+		return errListing
+	}
+	// Directories and playlists come back from listallinfo as well.
+	library.songs = make([]mpd.Attrs, 0, len(listing))
+	for _, song := range listing {
+		if song["file"] != "" {
+			library.songs = append(library.songs, song)
+		}
+	}
+	library.version = stats["db_update"]
+	return nil
+
+} // end refresh
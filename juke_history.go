@@ -0,0 +1,320 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has Juke's listening history (recording and statistics).
+*/
+
+package main
+
+import (
+	"bufio"
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/log"
+	"github.com/idealeric/juke/ui"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Listening history settings:
+const (
+	HISTORY_FILENAME       = "history" // file (in the data directory) that listens are appended to
+	HISTORY_LISTEN_PERCENT = 50        // a song counts as listened after this much of it is heard...
+	HISTORY_LISTEN_SECONDS = 240       // ...or after this many seconds, whichever is first
+	HISTORY_MAX_POLL_GAP   = 5         // progress larger than this between polls is a seek, not listening
+	HISTORY_TOP_COUNT      = 25        // entries shown in each of the top lists
+	HISTORY_RECENT_COUNT   = 50        // entries shown in the recently played list
+)
+
+// historyEntry is a single listen as stored in the history file.
+type historyEntry struct {
+	when     time.Time
+	duration int
+	listened int // seconds actually heard, at most duration
+	file     string
+	artist   string
+	album    string
+	title    string
+}
+
+// listenTracker follows the current song between polls and decides
+// when it has actually been listened to.
+type listenTracker struct {
+	songId      string
+	song        mpd.Attrs
+	started     time.Time
+	total       int
+	lastElapsed int
+	listened    int
+	recorded    bool
+	recordedAt  time.Time
+	onFinish    func(song mpd.Attrs, listened bool) // called (if set) as each song finishes
+}
+
+// observe is fed the current song and its progress on every poll. Only
+// forward progress that is small enough to be real listening (not a seek)
+// counts towards the threshold. Once the threshold is met, the song is
+// appended to the history file exactly once, when it finishes, along with
+// how much of it was heard.
+func (lt *listenTracker) observe(song mpd.Attrs, elapsed, total int) {
+
+	// A stream is no song of the library: it is neither kept in the history
+	// nor scrobbled, but it does end the song before it.
+	if isStream(song["file"]) {
+		lt.finish()
+		return
+	}
+
+	if song["Id"] != lt.songId || (elapsed < lt.lastElapsed && lt.recorded) {
+		// A new song (or the same song started over after being counted).
+		lt.finish()
+		lt.songId = song["Id"]
+		lt.song = song
+		lt.started = time.Now().Add(-time.Duration(elapsed) * time.Second)
+		lt.total = total
+		lt.lastElapsed = elapsed
+		lt.listened = 0
+		lt.recorded = false
+		return
+	}
+
+	if elapsed < lt.lastElapsed {
+		// Started over (or sought back) before it was counted: only the
+		// listening from here on counts towards the threshold.
+		lt.started = time.Now().Add(-time.Duration(elapsed) * time.Second)
+		lt.listened = 0
+	}
+
+	if delta := elapsed - lt.lastElapsed; delta > 0 && delta <= HISTORY_MAX_POLL_GAP {
+		lt.listened += delta
+	}
+	lt.lastElapsed = elapsed
+
+	if !lt.recorded && (lt.listened >= HISTORY_LISTEN_SECONDS || (total > 0 && lt.listened*100 >= total*HISTORY_LISTEN_PERCENT)) {
+		lt.recorded = true
+		lt.recordedAt = time.Now()
+	}
+
+} // end observe
+
+// finish is called when playback of the followed song ends (another song
+// starts, playback stops, or the connection is lost). The song is written
+// to the scrobble journal as either listened or skipped, and a listened
+// song to the history.
+func (lt *listenTracker) finish() {
+
+	if lt.songId != "" && lt.recorded {
+		appendHistory(&historyEntry{
+			when:     lt.recordedAt,
+			duration: lt.total,
+			listened: lt.listened,
+			file:     lt.song["file"],
+			artist:   lt.song["Artist"],
+			album:    lt.song["Album"],
+			title:    lt.song["Title"]})
+	}
+	if lt.songId != "" && lt.onFinish != nil {
+		lt.onFinish(lt.song, lt.recorded)
+	}
+	if lt.songId != "" && lt.listened > 0 {
+		appendScrobble(&scrobbleEntry{
+			started:  lt.started,
+			duration: lt.total,
+			listened: lt.recorded,
+			track:    lt.song["Track"],
+			mbid:     lt.song["MUSICBRAINZ_TRACKID"],
+			file:     lt.song["file"],
+			artist:   lt.song["Artist"],
+			album:    lt.song["Album"],
+			title:    lt.song["Title"]})
+	}
+	lt.songId = ""
+
+} // end finish
+
+// historyField makes a tag safe to store in the tab separated history file.
+func historyField(s string) string {
+
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return ' '
+		}
+		return r
+	}, s)
+
+} // end historyField
+
+// appendHistory writes a listen to the end of the history file.
+func appendHistory(entry *historyEntry) {
+
+	filename := jukeDataFilename(HISTORY_FILENAME)
+	if filename == "" {
+		return
+	}
+
+	historyFile, errOpen := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if errOpen != nil {
+		log.ErrorReport("appendHistory()", "Could not open history file ("+errOpen.Error()+").")
+		return
+	}
+	defer historyFile.Close()
+
+	line := strings.Join([]string{
+		strconv.FormatInt(entry.when.Unix(), 10),
+		strconv.Itoa(entry.duration),
+		historyField(entry.file),
+		historyField(entry.artist),
+		historyField(entry.album),
+		historyField(entry.title),
+		strconv.Itoa(entry.listened)}, "\t")
+	if _, errWrite := historyFile.WriteString(line + "\n"); errWrite != nil {
+		log.ErrorReport("appendHistory()", "Could not write to history file ("+errWrite.Error()+").")
+	}
+
+} // end appendHistory
+
+// readHistory reads every listen from the history file, oldest first.
+// Malformed lines are reported and skipped. Lines written before the
+// listened time was kept count as listened through.
+func readHistory() []*historyEntry {
+
+	var entries []*historyEntry
+
+	filename := jukeDataFilename(HISTORY_FILENAME)
+	if filename == "" {
+		return entries
+	}
+
+	historyFile, errOpen := os.Open(filename)
+	if errOpen != nil {
+		if !os.IsNotExist(errOpen) {
+			log.ErrorReport("readHistory()", "Could not open history file ("+errOpen.Error()+").")
+		}
+		return entries
+	}
+	defer historyFile.Close()
+
+	scanner := bufio.NewScanner(historyFile)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 6 && len(fields) != 7 {
+			log.ErrorReport("readHistory()", "Skipping malformed history line.")
+			continue
+		}
+		when, errWhen := strconv.ParseInt(fields[0], 10, 64)
+		duration, errDuration := strconv.Atoi(fields[1])
+		if errWhen != nil || errDuration != nil {
+			log.ErrorReport("readHistory()", "Skipping history line with a bad time or duration.")
+			continue
+		}
+		listened := duration
+		if len(fields) == 7 {
+			var errListened error
+			if listened, errListened = strconv.Atoi(fields[6]); errListened != nil {
+				log.ErrorReport("readHistory()", "Skipping history line with a bad listened time.")
+				continue
+			}
+		}
+		entries = append(entries, &historyEntry{
+			when:     time.Unix(when, 0),
+			duration: duration,
+			listened: listened,
+			file:     fields[2],
+			artist:   fields[3],
+			album:    fields[4],
+			title:    fields[5]})
+	}
+	if errScan := scanner.Err(); errScan != nil {
+		log.ErrorReport("readHistory()", "Could not read history file ("+errScan.Error()+").")
+	}
+
+	return entries
+
+} // end readHistory
+
+// statsRangeStart returns the earliest time that falls into a statistics range.
+func statsRangeStart(statsRange ui.StatsRange) time.Time {
+
+	now := time.Now()
+	switch statsRange {
+	case ui.STATS_TODAY:
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	case ui.STATS_WEEK:
+		return now.AddDate(0, 0, -7)
+	case ui.STATS_MONTH:
+		return now.AddDate(0, -1, 0)
+	case ui.STATS_YEAR:
+		return now.AddDate(-1, 0, 0)
+	}
+	return time.Time{} // ui.STATS_ALL_TIME
+
+} // end statsRangeStart
+
+// topCounts sorts a tally into the most played first (ties alphabetically)
+// and trims it to HISTORY_TOP_COUNT entries.
+func topCounts(tally map[string]int) []ui.StatsCount {
+
+	counts := make([]ui.StatsCount, 0, len(tally))
+	for label, count := range tally {
+		counts = append(counts, ui.StatsCount{Label: label, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count == counts[j].Count {
+			return counts[i].Label < counts[j].Label
+		}
+		return counts[i].Count > counts[j].Count
+	})
+	if len(counts) > HISTORY_TOP_COUNT {
+		counts = counts[:HISTORY_TOP_COUNT]
+	}
+	return counts
+
+} // end topCounts
+
+// computeStatistics builds the statistics for a range out of the history.
+func computeStatistics(entries []*historyEntry, statsRange ui.StatsRange) *ui.Statistics {
+
+	stats := &ui.Statistics{Range: statsRange}
+	since := statsRangeStart(statsRange)
+	artists, albums, tracks := make(map[string]int), make(map[string]int), make(map[string]int)
+
+	// Newest first, so the recent list falls out naturally.
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.when.Before(since) {
+			break
+		}
+		stats.Listens++
+		stats.ListeningTime += entry.listened
+		if entry.artist != "" {
+			artists[entry.artist]++
+		}
+		if entry.album != "" {
+			albums[entry.album]++
+		}
+		tracks[entry.title+" by "+entry.artist]++
+		if len(stats.Recent) < HISTORY_RECENT_COUNT {
+			stats.Recent = append(stats.Recent, &ui.HistoryRow{
+				File:   entry.file,
+				Played: entry.when,
+				Name:   entry.title,
+				Artist: entry.artist,
+				Album:  entry.album})
+		}
+	}
+
+	stats.TopArtists = topCounts(artists)
+	stats.TopAlbums = topCounts(albums)
+	stats.TopTracks = topCounts(tracks)
+	return stats
+
+} // end computeStatistics
+
+// showStatistics reads the history and displays the statistics for a range.
+func showStatistics(statsRange ui.StatsRange) {
+
+	ui.ShowStatistics(computeStatistics(readHistory(), statsRange))
+
+} // end showStatistics
@@ -0,0 +1,82 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has the global hotkeys, which reach Juke whatever
+window has the focus.
+*/
+
+package main
+
+import (
+	"github.com/idealeric/juke/config"
+	"github.com/idealeric/juke/hotkeys"
+	"github.com/idealeric/juke/log"
+	"github.com/idealeric/juke/ui"
+	"strconv"
+	"strings"
+)
+
+// How much (in percent) the volume hotkeys change the volume.
+const HOTKEY_VOLUME_STEP = 5
+
+// hotkeyRequest returns the request a hotkey stands for, nil if the hotkey
+// is unknown.
+func hotkeyRequest(name string) *jukeRequest {
+
+	switch name {
+	case "play_pause":
+		return &jukeRequest{state: PLAY_OR_PAUSE}
+	case "stop":
+		return &jukeRequest{state: STOP}
+	case "next":
+		return &jukeRequest{state: NEXT_TRACK}
+	case "previous":
+		return &jukeRequest{state: PREVIOUS_TRACK}
+	case "volume_up":
+		return &jukeRequest{state: VOLUME_STEP, volume: HOTKEY_VOLUME_STEP}
+	case "volume_down":
+		return &jukeRequest{state: VOLUME_STEP, volume: -HOTKEY_VOLUME_STEP}
+	}
+	if strings.HasPrefix(name, "rate_") {
+		if rating, errRating := strconv.Atoi(name[len("rate_"):]); errRating == nil && rating >= 0 && rating <= ui.CUR_PL_MAX_RATING {
+			return &jukeRequest{state: RATE_CURRENT, rating: rating}
+		}
+	}
+	return nil
+
+} // end hotkeyRequest
+
+// startHotkeys grabs the global hotkeys (if they are enabled), every one
+// pressed being sent to update() like any other request until done is
+// closed.
+func startHotkeys(updateChannel chan *jukeRequest, done chan bool) {
+
+	settings := config.Get().Hotkeys
+	if !settings.Enabled {
+		return
+	}
+	keys := make(map[string]string, len(settings.Keys))
+	for name, accelerator := range settings.Keys {
+		if hotkeyRequest(name) == nil {
+			log.ErrorReport("startHotkeys()", "There is no hotkey action called "+name+".")
+		} else {
+			keys[name] = accelerator
+		}
+	}
+
+	pressed, errListen := hotkeys.Listen(keys)
+	if errListen != nil {
+		log.ErrorReport("startHotkeys()", "Could not grab the global hotkeys ("+errListen.Error()+").")
+		return
+	}
+	go func() {
+		for name := range pressed {
+			select {
+			case updateChannel <- &jukeRequest{state: HOTKEY_PRESS, name: name}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+} // end startHotkeys
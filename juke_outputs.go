@@ -0,0 +1,214 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has Juke's management of MPD's audio outputs.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/config"
+	"github.com/idealeric/juke/log"
+	"github.com/idealeric/juke/ui"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How long listing output attributes may take before giving up.
+const OUTPUTS_TIMEOUT = 5 * time.Second
+
+// outputWatcher asks update() to refresh the outputs whenever MPD reports
+// that they have changed (through its idle command, on a connection of
+// its own).
+type outputWatcher struct {
+	watcher *mpd.Watcher
+}
+
+// watch starts watching the outputs of a server profile, until stopped or
+// done is closed.
+func (ow *outputWatcher) watch(server *config.Server, updateChannel chan *jukeRequest, done chan bool) {
+
+	network := "tcp"
+	if strings.HasPrefix(server.Address, "/") {
+		network = "unix"
+	}
+	watcher, errWatch := mpd.NewWatcher(network, server.Address, server.Password, "output")
+	if errWatch != nil {
+		log.ErrorReport("outputWatcher.watch()", "Could not watch the outputs ("+errWatch.Error()+").")
+		return
+	}
+	ow.watcher = watcher
+
+	go func() {
+		for range watcher.Event {
+			select {
+			case updateChannel <- &jukeRequest{state: OUTPUTS_REFRESH}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	go func() {
+		for errEvent := range watcher.Error {
+			log.ErrorReport("outputWatcher.watch()", "Lost track of the outputs ("+errEvent.Error()+").")
+		}
+	}()
+
+} // end watch
+
+// stop stops watching, if watching.
+func (ow *outputWatcher) stop() {
+
+	if ow.watcher != nil {
+		if errClose := ow.watcher.Close(); errClose != nil {
+			log.ErrorReport("outputWatcher.stop()", "Could not close the watcher ("+errClose.Error()+").")
+		}
+		ow.watcher = nil
+	}
+
+} // end stop
+
+// quoteArgument quotes an argument of an MPD command.
+func quoteArgument(arg string) string {
+
+	return `"` + strings.Replace(strings.Replace(arg, `\`, `\\`, -1), `"`, `\"`, -1) + `"`
+
+} // end quoteArgument
+
+// outputAttributes returns the plugin attributes of every output of a
+// partition, by ID. An output may have any number of them, all under the
+// same key, which the MPD library (keeping a map per output) cannot return.
+// So the outputs are listed over a plain connection of Juke's own.
+func outputAttributes(server *config.Server, partition string) (map[int][]string, error) {
+
+	network := "tcp"
+	if strings.HasPrefix(server.Address, "/") {
+		network = "unix"
+	}
+	conn, errDial := net.DialTimeout(network, server.Address, OUTPUTS_TIMEOUT)
+	if errDial != nil {
+		return nil, errDial
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(OUTPUTS_TIMEOUT))
+
+	reader := bufio.NewReader(conn)
+	// readReply reads lines up to the closing OK, failing on an ACK.
+	readReply := func(onLine func(key, value string)) error {
+		for {
+			line, errRead := reader.ReadString('\n')
+			if errRead != nil {
+				return errRead
+			}
+			line = strings.TrimRight(line, "\n")
+			if line == "OK" || strings.HasPrefix(line, "OK MPD ") {
+				return nil
+			}
+			if strings.HasPrefix(line, "ACK ") {
+				return fmt.Errorf("%s", line)
+			}
+			if parts := strings.SplitN(line, ": ", 2); len(parts) == 2 {
+				onLine(parts[0], parts[1])
+			}
+		}
+	}
+
+	// The greeting.
+	if errGreeting := readReply(func(key, value string) {}); errGreeting != nil {
+		return nil, errGreeting
+	}
+	if server.Password != "" {
+		fmt.Fprintf(conn, "password %s\n", quoteArgument(server.Password))
+		if errPassword := readReply(func(key, value string) {}); errPassword != nil {
+			return nil, errPassword
+		}
+	}
+
+	if partition != ui.DEFAULT_PARTITION {
+		fmt.Fprintf(conn, "partition %s\n", quoteArgument(partition))
+		if errPartition := readReply(func(key, value string) {}); errPartition != nil {
+			return nil, errPartition
+		}
+	}
+
+	attributes := make(map[int][]string)
+	outputId := -1
+	fmt.Fprintf(conn, "outputs\n")
+	errOutputs := readReply(func(key, value string) {
+		switch key {
+		case "outputid":
+			outputId, _ = strconv.Atoi(value)
+		case "attribute":
+			attributes[outputId] = append(attributes[outputId], value)
+		}
+	})
+	return attributes, errOutputs
+
+} // end outputAttributes
+
+// listOutputs returns the outputs of the partition, as shown in the UI.
+func listOutputs(mpdConnection *mpd.Client, partition string) []*ui.OutputRow {
+
+	outputs, errOutputs := mpdConnection.Command("outputs").AttrsList("outputid")
+	if errOutputs != nil {
+		log.ErrorReport("listOutputs()", "Could not list the outputs ("+errOutputs.Error()+").")
+		return nil
+	}
+	// Attributes are a nicety, the outputs are still shown without them.
+	attributes, errAttributes := outputAttributes(config.Get().CurrentServer(), partition)
+	if errAttributes != nil {
+		log.ErrorReport("listOutputs()", "Could not list the output attributes ("+errAttributes.Error()+").")
+	}
+
+	rows := make([]*ui.OutputRow, 0, len(outputs))
+	for _, output := range outputs {
+		id, errId := strconv.Atoi(output["outputid"])
+		if errId != nil {
+			continue
+		}
+		rows = append(rows, &ui.OutputRow{
+			ID:         id,
+			Name:       output["outputname"],
+			Plugin:     output["plugin"],
+			Enabled:    output["outputenabled"] == "1",
+			Attributes: attributes[id]})
+	}
+	return rows
+
+} // end listOutputs
+
+// changeOutput enables, disables or toggles an output.
+func changeOutput(mpdConnection *mpd.Client, id int, action ui.OutputAction) error {
+
+	switch action {
+	case ui.OUTPUT_ENABLE:
+		return mpdConnection.Command("enableoutput %d", id).OK()
+	case ui.OUTPUT_DISABLE:
+		return mpdConnection.Command("disableoutput %d", id).OK()
+	}
+	return mpdConnection.Command("toggleoutput %d", id).OK()
+
+} // end changeOutput
+
+// moveOutput moves an output into another partition. MPD only moves
+// outputs into the partition of the client asking, so this is done on a
+// connection of its own.
+func moveOutput(name, partition string) error {
+
+	conn, errDial := dialServer(config.Get().CurrentServer())
+	if errDial != nil {
+		return errDial
+	}
+	defer conn.Close()
+
+	if errPartition := switchPartition(conn, partition); errPartition != nil {
+		return errPartition
+	}
+	return conn.Command("moveoutput %s", name).OK()
+
+} // end moveOutput
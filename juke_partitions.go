@@ -0,0 +1,49 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has Juke's handling of MPD partitions (each with its
+own queue and player).
+*/
+
+package main
+
+import (
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/ui"
+)
+
+// listPartitions returns the names of the server's partitions (none if
+// the server does not support them).
+func listPartitions(mpdConnection *mpd.Client) []string {
+
+	partitions, errPartitions := mpdConnection.Command("listpartitions").Strings("partition")
+	if errPartitions != nil {
+		return nil
+	}
+	return partitions
+
+} // end listPartitions
+
+// showPartitions refreshes the partitions window, and the partitions the
+// outputs window offers to move outputs to (every one but the current).
+func showPartitions(mpdConnection *mpd.Client, current string) {
+
+	partitions := listPartitions(mpdConnection)
+	ui.SetPartitions(partitions, current)
+
+	var others []string
+	for _, partition := range partitions {
+		if partition != current {
+			others = append(others, partition)
+		}
+	}
+	ui.SetOutputPartitions(others)
+
+} // end showPartitions
+
+// switchPartition moves the connection to another partition.
+func switchPartition(mpdConnection *mpd.Client, partition string) error {
+
+	return mpdConnection.Command("partition %s", partition).OK()
+
+} // end switchPartition
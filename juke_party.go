@@ -0,0 +1,644 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has Juke's party mode: a web page on the LAN where
+guests search the library, request songs and vote on each other's requests.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/config"
+	"github.com/idealeric/juke/log"
+	"github.com/idealeric/juke/ui"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Party mode settings:
+const (
+	PARTY_SEARCH_LIMIT  = 50              // results returned to a search of the library
+	PARTY_REPLY_TIMEOUT = 5 * time.Second // how long a guest waits on update()
+	PARTY_MAX_PRIORITY  = 255             // MPD's highest queue priority
+	PARTY_PRIORITY_STEP = 10              // priority gained per vote
+	PARTY_RATE_WINDOW   = time.Hour       // window for requests_per_hour
+	PARTY_NOT_QUEUED    = -1              // songId of a request not yet in the MPD queue
+	PARTY_HTTP_TIMEOUT  = 10 * time.Second
+)
+
+// partyRequest is a song requested by a guest.
+type partyRequest struct {
+	id       int
+	uri      string
+	title    string
+	artist   string
+	guest    string
+	voters   map[string]bool
+	approved bool
+	songId   int
+}
+
+// partyResult is a search result as sent to guests.
+type partyResult struct {
+	URI    string `json:"uri"`
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Album  string `json:"album"`
+}
+
+// partyPending is a request as sent to guests.
+type partyPending struct {
+	ID       int    `json:"id"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Votes    int    `json:"votes"`
+	Approved bool   `json:"approved"`
+	Voted    bool   `json:"voted"`
+}
+
+// partyMode is the state of a running party. The guest handlers run on
+// their own goroutines, so everything is guarded by the mutex; anything
+// touching MPD is handed to update() through the update channel.
+type partyMode struct {
+	sync.Mutex
+	server        *http.Server
+	updateChannel chan *jukeRequest
+	requests      []*partyRequest
+	nextId        int
+	guestRequests map[string][]time.Time
+	playing       string // songid last seen by apply(), only touched by update()
+	randomSet     bool   // random mode was turned on for the party (and is turned off after), only touched by update()
+}
+
+// guestOf identifies a guest by their address on the LAN.
+func guestOf(r *http.Request) string {
+
+	host, _, errSplit := net.SplitHostPort(r.RemoteAddr)
+	if errSplit != nil {
+		return r.RemoteAddr
+	}
+	return host
+
+} // end guestOf
+
+// startParty serves the guest page until stop is called.
+func startParty(updateChannel chan *jukeRequest) (*partyMode, error) {
+
+	party := &partyMode{
+		updateChannel: updateChannel,
+		guestRequests: make(map[string][]time.Time)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", party.serveIndex)
+	mux.HandleFunc("/search", party.serveSearch)
+	mux.HandleFunc("/request", party.serveRequest)
+	mux.HandleFunc("/vote", party.serveVote)
+	mux.HandleFunc("/pending", party.servePending)
+	party.server = &http.Server{
+		Addr:         config.Get().Party.Listen,
+		Handler:      mux,
+		ReadTimeout:  PARTY_HTTP_TIMEOUT,
+		WriteTimeout: PARTY_HTTP_TIMEOUT}
+
+	// Listening is done up front so that a taken port is reported to the host.
+	listener, errListen := net.Listen("tcp", party.server.Addr)
+	if errListen != nil {
+		return nil, errListen
+	}
+	go func() {
+		if errServe := party.server.Serve(listener); errServe != nil && errServe != http.ErrServerClosed {
+			log.ErrorReport("startParty()", "Could not serve the guest page ("+errServe.Error()+").")
+		}
+	}()
+	log.MessageReport("startParty()", "Serving the guest page on "+config.Get().Party.Listen+".")
+
+	return party, nil
+
+} // end startParty
+
+// stop stops serving the guest page. Requests already queued stay queued.
+func (party *partyMode) stop() {
+
+	if errClose := party.server.Close(); errClose != nil {
+		log.ErrorReport("partyMode.stop()", "Could not stop serving the guest page ("+errClose.Error()+").")
+	}
+
+} // end stop
+
+// setRandom turns MPD's random mode on for the party (MPD only honours
+// queue priorities in random mode), noting whether it was off before.
+// Called from update().
+func (party *partyMode) setRandom(mpdConnection *mpd.Client) {
+
+	status, errStatus := mpdConnection.Status()
+	if errStatus != nil {
+		log.ErrorReport("partyMode.setRandom()", "Could not establish MPD status ("+errStatus.Error()+").")
+		return
+	}
+	if status["random"] == "1" {
+		return
+	}
+	if errRandom := mpdConnection.Random(true); errRandom != nil {
+		log.ErrorReport("partyMode.setRandom()", "Could not mpd.Random() ("+errRandom.Error()+").")
+	} else {
+		party.randomSet = true
+	}
+
+} // end setRandom
+
+// restoreRandom turns MPD's random mode back off if the party turned it
+// on. Called from update().
+func (party *partyMode) restoreRandom(mpdConnection *mpd.Client) {
+
+	if !party.randomSet {
+		return
+	}
+	if errRandom := mpdConnection.Random(false); errRandom != nil {
+		log.ErrorReport("partyMode.restoreRandom()", "Could not mpd.Random() ("+errRandom.Error()+").")
+	}
+	party.randomSet = false
+
+} // end restoreRandom
+
+// forget drops the requests already queued, as their song IDs mean nothing
+// to another server (or to the same one restarted). Requests not yet queued
+// are queued on the next sync. Called from update() on a new connection.
+func (party *partyMode) forget() {
+
+	party.Lock()
+	remaining := party.requests[:0]
+	for _, request := range party.requests {
+		if request.songId == PARTY_NOT_QUEUED {
+			remaining = append(remaining, request)
+		}
+	}
+	party.requests = remaining
+	party.Unlock()
+	party.playing = ""
+
+} // end forget
+
+// prune drops the requests of each guest that are past the rate window, and
+// the guests left with none. The lock must be held.
+func (party *partyMode) prune() {
+
+	for guest, times := range party.guestRequests {
+		var recent []time.Time
+		for _, when := range times {
+			if time.Since(when) < PARTY_RATE_WINDOW {
+				recent = append(recent, when)
+			}
+		}
+		if len(recent) == 0 {
+			delete(party.guestRequests, guest)
+		} else {
+			party.guestRequests[guest] = recent
+		}
+	}
+
+} // end prune
+
+// lookup finds a song of the library by its URI, through update(). Nothing
+// is returned if the song is not in the library (or the jukebox does not
+// answer).
+func (party *partyMode) lookup(uri string) (partyResult, bool) {
+
+	reply := make(chan []partyResult, 1)
+	go func() {
+		party.updateChannel <- &jukeRequest{state: PARTY_LOOKUP, name: uri, partyReply: reply}
+	}()
+
+	select {
+	case found := <-reply:
+		if len(found) == 1 {
+			return found[0], true
+		}
+	case <-time.After(PARTY_REPLY_TIMEOUT):
+	}
+	return partyResult{}, false
+
+} // end lookup
+
+// sync asks update() to bring the MPD queue and the host's view in line
+// with the requests.
+func (party *partyMode) sync() {
+
+	go func() {
+		party.updateChannel <- &jukeRequest{state: PARTY_SYNC}
+	}()
+
+} // end sync
+
+// writeJSON replies to a guest with a JSON value.
+func writeJSON(w http.ResponseWriter, value interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	if errEncode := json.NewEncoder(w).Encode(value); errEncode != nil {
+		log.ErrorReport("writeJSON()", "Could not reply to a guest ("+errEncode.Error()+").")
+	}
+
+} // end writeJSON
+
+// serveIndex serves the guest page itself.
+func (party *partyMode) serveIndex(w http.ResponseWriter, r *http.Request) {
+
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(PARTY_PAGE))
+
+} // end serveIndex
+
+// serveSearch searches the library for a guest.
+func (party *partyMode) serveSearch(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		writeJSON(w, []partyResult{})
+		return
+	}
+
+	reply := make(chan []partyResult, 1)
+	go func() {
+		party.updateChannel <- &jukeRequest{state: PARTY_SEARCH, name: query, partyReply: reply}
+	}()
+
+	select {
+	case results := <-reply:
+		writeJSON(w, results)
+	case <-time.After(PARTY_REPLY_TIMEOUT):
+		http.Error(w, "The jukebox is not answering.", http.StatusServiceUnavailable)
+	}
+
+} // end serveSearch
+
+// serveRequest takes a guest's song request, subject to their rate limit.
+// Only songs of the library can be requested, and they are shown by their
+// own tags rather than whatever the guest sent.
+func (party *partyMode) serveRequest(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" {
+		http.Error(w, "Requests must be POSTed.", http.StatusMethodNotAllowed)
+		return
+	}
+	guest := guestOf(r)
+	uri := r.FormValue("uri")
+	if uri == "" {
+		http.Error(w, "No song given.", http.StatusBadRequest)
+		return
+	}
+	song, found := party.lookup(uri)
+	if !found {
+		http.Error(w, "That song is not in the library.", http.StatusNotFound)
+		return
+	}
+
+	party.Lock()
+	party.prune()
+	if len(party.guestRequests[guest]) >= config.Get().Party.RequestsPerHour {
+		party.Unlock()
+		http.Error(w, "You have made enough requests for now, try again later.", http.StatusTooManyRequests)
+		return
+	}
+	party.guestRequests[guest] = append(party.guestRequests[guest], time.Now())
+	party.nextId++
+	party.requests = append(party.requests, &partyRequest{
+		id:       party.nextId,
+		uri:      song.URI,
+		title:    song.Title,
+		artist:   song.Artist,
+		guest:    guest,
+		voters:   map[string]bool{guest: true},
+		approved: !config.Get().Party.RequireApproval,
+		songId:   PARTY_NOT_QUEUED})
+	party.Unlock()
+
+	party.sync()
+	writeJSON(w, "ok")
+
+} // end serveRequest
+
+// serveVote takes a guest's upvote (one per guest per request).
+func (party *partyMode) serveVote(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" {
+		http.Error(w, "Votes must be POSTed.", http.StatusMethodNotAllowed)
+		return
+	}
+	id, errId := strconv.Atoi(r.FormValue("id"))
+	if errId != nil {
+		http.Error(w, "No request given.", http.StatusBadRequest)
+		return
+	}
+
+	found := false
+	party.Lock()
+	for _, request := range party.requests {
+		if request.id == id {
+			request.voters[guestOf(r)] = true
+			found = true
+		}
+	}
+	party.Unlock()
+
+	if !found {
+		http.Error(w, "That request is no longer pending.", http.StatusNotFound)
+		return
+	}
+	party.sync()
+	writeJSON(w, "ok")
+
+} // end serveVote
+
+// servePending lists the outstanding requests, most votes first.
+func (party *partyMode) servePending(w http.ResponseWriter, r *http.Request) {
+
+	guest := guestOf(r)
+	party.Lock()
+	pending := make([]partyPending, len(party.requests))
+	for i, request := range party.sorted() {
+		pending[i] = partyPending{
+			ID:       request.id,
+			Title:    request.title,
+			Artist:   request.artist,
+			Votes:    len(request.voters),
+			Approved: request.approved,
+			Voted:    request.voters[guest]}
+	}
+	party.Unlock()
+
+	writeJSON(w, pending)
+
+} // end servePending
+
+// sorted returns the requests with the most votes first (oldest first among
+// equals). The lock must be held.
+func (party *partyMode) sorted() []*partyRequest {
+
+	sorted := make([]*partyRequest, len(party.requests))
+	copy(sorted, party.requests)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].voters) > len(sorted[j].voters)
+	})
+	return sorted
+
+} // end sorted
+
+// decide approves or rejects a request awaiting the host.
+func (party *partyMode) decide(id int, approve bool) {
+
+	party.Lock()
+	for i, request := range party.requests {
+		if request.id == id {
+			if approve {
+				request.approved = true
+			} else {
+				party.requests = append(party.requests[:i], party.requests[i+1:]...)
+			}
+			break
+		}
+	}
+	party.Unlock()
+
+} // end decide
+
+// searchLibrary runs a search of the library (a guest's or the command
+// palette's) against MPD. Called from update().
+func searchLibrary(mpdConnection *mpd.Client, query string) []partyResult {
+
+	results := []partyResult{}
+	found, errSearch := mpdConnection.Search("any", query)
+	if errSearch != nil {
+		log.ErrorReport("searchLibrary()", "Could not search the library ("+errSearch.Error()+").")
+		return results
+	}
+	for _, song := range found {
+		if len(results) == PARTY_SEARCH_LIMIT {
+			break
+		}
+		if song["file"] != "" {
+			results = append(results, partyResult{URI: song["file"], Title: song["Title"], Artist: song["Artist"], Album: song["Album"]})
+		}
+	}
+	return results
+
+} // end searchLibrary
+
+// lookupLibrary finds a song of the library by its URI (for a guest's
+// request). Nothing is returned if there is no such song. Called from
+// update().
+func lookupLibrary(mpdConnection *mpd.Client, uri string) []partyResult {
+
+	found, errFind := mpdConnection.Find("file", uri)
+	if errFind != nil {
+		log.ErrorReport("lookupLibrary()", "Could not look up a song ("+errFind.Error()+").")
+		return []partyResult{}
+	}
+	for _, song := range found {
+		if song["file"] == uri {
+			return []partyResult{{URI: song["file"], Title: song["Title"], Artist: song["Artist"], Album: song["Album"]}}
+		}
+	}
+	return []partyResult{}
+
+} // end lookupLibrary
+
+// partyQueued is what apply() needs of a request while the lock is not held.
+type partyQueued struct {
+	id       int
+	uri      string
+	songId   int
+	priority int
+}
+
+// apply queues approved requests and sets the queue priority of every
+// queued request from its votes, so that MPD (in random mode) plays the
+// most wanted songs first. Requests that have started playing are done
+// and dropped, as are requests whose song has been taken out of the queue.
+// The host's view is refreshed. Called from update(). The lock is only
+// held to copy the requests out and to bring the results back, never
+// while talking to MPD.
+func (party *partyMode) apply(mpdConnection *mpd.Client, status mpd.Attrs) {
+
+	party.playing = status["songid"]
+
+	var queued []partyQueued
+	party.Lock()
+	// Drop the request that is playing now; it has been honoured.
+	if playing, errPlaying := strconv.Atoi(status["songid"]); errPlaying == nil && status["state"] != "stop" {
+		remaining := party.requests[:0]
+		for _, request := range party.requests {
+			if request.songId != playing {
+				remaining = append(remaining, request)
+			}
+		}
+		party.requests = remaining
+	}
+	for _, request := range party.requests {
+		if request.approved || request.songId != PARTY_NOT_QUEUED {
+			priority := len(request.voters) * PARTY_PRIORITY_STEP
+			if priority > PARTY_MAX_PRIORITY {
+				priority = PARTY_MAX_PRIORITY
+			}
+			queued = append(queued, partyQueued{id: request.id, uri: request.uri, songId: request.songId, priority: priority})
+		}
+	}
+	party.Unlock()
+
+	// The songs still in the queue, to tell which requests were taken out.
+	inQueue := make(map[int]bool)
+	if len(queued) > 0 {
+		curPlay, errPlay := mpdConnection.PlaylistInfo(-1, -1)
+		if errPlay != nil {
+			log.ErrorReport("partyMode.apply()", "Could not list the current playlist ("+errPlay.Error()+").")
+			return
+		}
+		for _, song := range curPlay {
+			if songId, errId := strconv.Atoi(song["Id"]); errId == nil {
+				inQueue[songId] = true
+			}
+		}
+	}
+
+	gone := make(map[int]bool)
+	for i := range queued {
+		if queued[i].songId == PARTY_NOT_QUEUED {
+			songId, errAdd := mpdConnection.AddId(queued[i].uri, -1)
+			if errAdd != nil {
+				log.ErrorReport("partyMode.apply()", "Could not queue a request ("+errAdd.Error()+").")
+				continue
+			}
+			queued[i].songId = songId
+		} else if !inQueue[queued[i].songId] {
+			gone[queued[i].id] = true
+			continue
+		}
+		if errPrio := mpdConnection.Command("prioid %d %d", queued[i].priority, queued[i].songId).OK(); errPrio != nil {
+			log.ErrorReport("partyMode.apply()", "Could not set a request's priority ("+errPrio.Error()+").")
+		}
+	}
+
+	songIds := make(map[int]int, len(queued))
+	for _, request := range queued {
+		songIds[request.id] = request.songId
+	}
+
+	party.Lock()
+	remaining := party.requests[:0]
+	for _, request := range party.requests {
+		if gone[request.id] {
+			continue
+		}
+		if songId, found := songIds[request.id]; found {
+			request.songId = songId
+		}
+		remaining = append(remaining, request)
+	}
+	party.requests = remaining
+	rows := make([]*ui.PartyRow, 0, len(party.requests))
+	for _, request := range party.sorted() {
+		rows = append(rows, &ui.PartyRow{
+			ID:       request.id,
+			Name:     request.title,
+			Artist:   request.artist,
+			Guest:    request.guest,
+			Votes:    len(request.voters),
+			Approved: request.approved})
+	}
+	party.Unlock()
+	ui.SetPartyRequests(rows)
+
+} // end apply
+
+// partyLocked tells whether a request is one that the party's lock on
+// Juke's controls refuses: anything that changes the queue, playback, the
+// outputs or the server in use. Party requests themselves are never locked.
+func partyLocked(state jukeStateRequest) bool {
+
+	switch state {
+	case NEXT_TRACK, PREVIOUS_TRACK, PLAY_OR_PAUSE, STOP, PROGRESS_CHANGE, SEEK_RELATIVE,
+		CHANGE_TRACK, SORT_PLAYLIST, REMOVE_PLAYLIST, CLEAR_PLAYLIST, ADD_SONGS,
+		SMART_PLAYLIST, AUTODJ_TOGGLE, QUEUE_OPERATION, UNDO_QUEUE,
+		VOLUME_CHANGE, VOLUME_STEP, SETTING_CHANGE, OUTPUT_CHANGE, OUTPUT_MOVE,
+		SWITCH_SERVER, SEND_PLAYBACK, MIRROR_TOGGLE, GROUP_TOGGLE,
+		PARTITION_SWITCH, PARTITION_NEW, PARTITION_DELETE, HOTKEY_PRESS:
+		return true
+	}
+	return false
+
+} // end partyLocked
+
+// PARTY_PAGE is the page guests see.
+const PARTY_PAGE = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Juke</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 0 auto; padding: 1em; }
+li { margin: 0.4em 0; }
+button { margin-left: 0.5em; }
+.waiting { color: #999; }
+</style>
+</head>
+<body>
+<h1>Juke</h1>
+<form id="search"><input id="q" placeholder="Search for a song" autofocus> <button>Search</button></form>
+<ul id="results"></ul>
+<h2>Requests</h2>
+<ul id="pending"></ul>
+<p id="message"></p>
+<script>
+function el(tag, text) { var e = document.createElement(tag); e.textContent = text; return e; }
+function say(text) { document.getElementById("message").textContent = text; }
+function post(path, data) {
+	return fetch(path, {method: "POST", body: new URLSearchParams(data)}).then(function(r) {
+		if (!r.ok) { return r.text().then(say); }
+		say(""); refresh();
+	});
+}
+function refresh() {
+	fetch("/pending").then(function(r) { return r.json(); }).then(function(pending) {
+		var list = document.getElementById("pending");
+		list.innerHTML = "";
+		pending.forEach(function(p) {
+			var li = el("li", p.title + " by " + p.artist + " (" + p.votes + ")");
+			if (!p.approved) { li.className = "waiting"; }
+			if (!p.voted) {
+				var b = el("button", "+1");
+				b.onclick = function() { post("/vote", {id: p.id}); };
+				li.appendChild(b);
+			}
+			list.appendChild(li);
+		});
+	});
+}
+document.getElementById("search").onsubmit = function(e) {
+	e.preventDefault();
+	fetch("/search?q=" + encodeURIComponent(document.getElementById("q").value)).then(function(r) { return r.json(); }).then(function(results) {
+		var list = document.getElementById("results");
+		list.innerHTML = "";
+		results.forEach(function(s) {
+			var li = el("li", s.title + " by " + s.artist + " from " + s.album);
+			var b = el("button", "Request");
+			b.onclick = function() { post("/request", {uri: s.uri}); };
+			li.appendChild(b);
+			list.appendChild(li);
+		});
+	});
+};
+refresh();
+setInterval(refresh, 5000);
+</script>
+</body>
+</html>
+`
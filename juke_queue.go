@@ -0,0 +1,259 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has the operations on selected songs of the current
+playlist (play next, move, crop, shuffle and priorities).
+*/
+
+package main
+
+import (
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/ui"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// queueIds returns the song IDs of the current playlist, in order.
+func queueIds(mpdConnection *mpd.Client) ([]int, error) {
+
+	queue, errQueue := mpdConnection.PlaylistInfo(-1, -1)
+	if errQueue != nil {
+		return nil, errQueue
+	}
+	ids := make([]int, 0, len(queue))
+	for _, song := range queue {
+		if id, errId := strconv.Atoi(song["Id"]); errId == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+
+} // end queueIds
+
+// queueMove moves a song (by ID) to a position of the playlist.
+type queueMove struct {
+	id int
+	to int
+}
+
+// reorderQueue adds the moves that take the playlist from its current order
+// to the desired one (the same IDs, rearranged) to a command list.
+func reorderQueue(cmdList *mpd.CommandList, current, desired []int) {
+
+	for _, move := range queueMoves(current, desired) {
+		cmdList.MoveId(move.id, move.to)
+	}
+
+} // end reorderQueue
+
+// queueMoves returns the moves that take the playlist from its current
+// order to the desired one, moving as few songs as possible: the longest
+// run of songs already in the right relative order stays put and every
+// other song is moved next to the song it must follow.
+func queueMoves(current, desired []int) []queueMove {
+
+	target := make(map[int]int, len(desired))
+	for pos, id := range desired {
+		target[id] = pos
+	}
+	stays := stayingSongs(current, target)
+
+	// order mirrors the playlist as the moves are made.
+	var moves []queueMove
+	order := make([]int, len(current))
+	copy(order, current)
+	for pos, id := range desired {
+		if stays[id] {
+			continue
+		}
+		from := indexOf(order, id)
+		order = append(order[:from], order[from+1:]...)
+		to := 0
+		if pos > 0 {
+			to = indexOf(order, desired[pos-1]) + 1
+		}
+		order = append(order[:to], append([]int{id}, order[to:]...)...)
+		moves = append(moves, queueMove{id: id, to: to})
+	}
+	return moves
+
+} // end queueMoves
+
+// stayingSongs finds the longest subsequence of the playlist that is already
+// in its desired order (a longest increasing subsequence of the desired
+// positions, in n log n).
+func stayingSongs(current []int, target map[int]int) map[int]bool {
+
+	// tails[l] is the index in current of the smallest ending of a run of
+	// length l+1, previous links each index to the one before it in its run.
+	var tails []int
+	previous := make([]int, len(current))
+	for i, id := range current {
+		l := sort.Search(len(tails), func(l int) bool {
+			return target[current[tails[l]]] >= target[id]
+		})
+		previous[i] = -1
+		if l > 0 {
+			previous[i] = tails[l-1]
+		}
+		if l == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[l] = i
+		}
+	}
+
+	stays := make(map[int]bool, len(tails))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+			stays[current[i]] = true
+		}
+	}
+	return stays
+
+} // end stayingSongs
+
+// indexOf returns the position of a song ID in a playlist (-1 if absent).
+func indexOf(order []int, id int) int {
+
+	for i, other := range order {
+		if other == id {
+			return i
+		}
+	}
+	return -1
+
+} // end indexOf
+
+// splitSelected splits the playlist into the selected songs and the rest,
+// both in playlist order.
+func splitSelected(current []int, selected map[int]bool) ([]int, []int) {
+
+	var chosen, rest []int
+	for _, id := range current {
+		if selected[id] {
+			chosen = append(chosen, id)
+		} else {
+			rest = append(rest, id)
+		}
+	}
+	return chosen, rest
+
+} // end splitSelected
+
+// queueOperation applies an operation of the popup menu to the selected
+// songs, as a single command list.
+func queueOperation(mpdConnection *mpd.Client, operation ui.QueueOperation, priority int, ids []int) error {
+
+	current, errCurrent := queueIds(mpdConnection)
+	if errCurrent != nil {
+		return errCurrent
+	}
+	selected := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+	chosen, rest := splitSelected(current, selected)
+
+	// The command list has no prioid of its own, but prioid takes any
+	// number of songs, so a single command does.
+	if operation == ui.QUEUE_PRIORITY {
+		if len(chosen) == 0 {
+			return nil
+		}
+		args := make([]interface{}, 0, len(chosen)+1)
+		format := "prioid %d"
+		args = append(args, priority)
+		for _, id := range chosen {
+			format += " %d"
+			args = append(args, id)
+		}
+		return mpdConnection.Command(format, args...).OK()
+	}
+
+	// Anything asked of MPD outside the command list is asked before it is
+	// begun: once begun, it must be ended on every path.
+	var status mpd.Attrs
+	if operation == ui.QUEUE_PLAY_NEXT {
+		var errStatus error
+		if status, errStatus = mpdConnection.Status(); errStatus != nil {
+			return errStatus
+		}
+	}
+
+	cmdList := mpdConnection.BeginCommandList()
+
+	switch operation {
+
+	case ui.QUEUE_PLAY_NEXT:
+		playing, errPlaying := strconv.Atoi(status["songid"])
+		if errPlaying != nil || status["state"] == "stop" {
+			// Nothing is playing, so "next" is the top.
+			reorderQueue(cmdList, current, append(chosen, rest...))
+			break
+		}
+		// The playing song stays where it is, even if selected.
+		delete(selected, playing)
+		chosen, rest = splitSelected(current, selected)
+		var desired []int
+		for i, id := range rest {
+			desired = append(desired, id)
+			if id == playing {
+				desired = append(desired, chosen...)
+				desired = append(desired, rest[i+1:]...)
+				break
+			}
+		}
+		reorderQueue(cmdList, current, desired)
+
+	case ui.QUEUE_MOVE_TOP:
+		reorderQueue(cmdList, current, append(chosen, rest...))
+
+	case ui.QUEUE_MOVE_BOTTOM:
+		reorderQueue(cmdList, current, append(rest, chosen...))
+
+	case ui.QUEUE_CROP:
+		for _, id := range rest {
+			cmdList.DeleteId(id)
+		}
+
+	case ui.QUEUE_SHUFFLE:
+		// A run of songs is shuffled by MPD itself; scattered songs are
+		// shuffled among their own places.
+		first, last := -1, -1
+		for pos, id := range current {
+			if selected[id] {
+				if first < 0 {
+					first = pos
+				}
+				last = pos
+			}
+		}
+		if first >= 0 && last-first+1 == len(chosen) {
+			cmdList.Shuffle(first, last+1)
+			break
+		}
+		shuffled := make([]int, len(chosen))
+		copy(shuffled, chosen)
+		rand.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		desired := make([]int, len(current))
+		next := 0
+		for pos, id := range current {
+			if selected[id] {
+				desired[pos] = shuffled[next]
+				next++
+			} else {
+				desired[pos] = id
+			}
+		}
+		reorderQueue(cmdList, current, desired)
+
+	}
+
+	return cmdList.End()
+
+} // end queueOperation
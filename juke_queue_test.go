@@ -0,0 +1,85 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file tests the reordering of the current playlist.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// applyMoves plays moves out on a playlist as MPD's moveid would.
+func applyMoves(current []int, moves []queueMove) []int {
+
+	order := append([]int(nil), current...)
+	for _, move := range moves {
+		from := indexOf(order, move.id)
+		order = append(order[:from], order[from+1:]...)
+		order = append(order[:move.to], append([]int{move.id}, order[move.to:]...)...)
+	}
+	return order
+
+} // end applyMoves
+
+func TestQueueMoves(t *testing.T) {
+
+	tests := []struct {
+		name             string
+		current, desired []int
+		moves            int // the fewest moves that do it
+	}{
+		{"empty", nil, nil, 0},
+		{"single", []int{7}, []int{7}, 0},
+		{"unchanged", []int{1, 2, 3, 4}, []int{1, 2, 3, 4}, 0},
+		{"swap", []int{1, 2}, []int{2, 1}, 1},
+		{"last to front", []int{1, 2, 3, 4, 5}, []int{5, 1, 2, 3, 4}, 1},
+		{"front to last", []int{1, 2, 3, 4, 5}, []int{2, 3, 4, 5, 1}, 1},
+		{"reversed", []int{1, 2, 3, 4, 5}, []int{5, 4, 3, 2, 1}, 4},
+		{"two runs", []int{10, 11, 12, 20, 21, 22}, []int{20, 21, 22, 10, 11, 12}, 3},
+		{"interleaved", []int{1, 2, 3, 4, 5, 6}, []int{1, 4, 2, 5, 3, 6}, 2},
+		{"scattered", []int{3, 1, 4, 5, 9, 2, 6, 8, 7}, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}, 4},
+		{"sparse ids", []int{42, 7, 1000, 3}, []int{3, 7, 42, 1000}, 2},
+	}
+
+	for _, test := range tests {
+		moves := queueMoves(test.current, test.desired)
+		if len(moves) != test.moves {
+			t.Errorf("%s: %d moves, want %d", test.name, len(moves), test.moves)
+		}
+		if got := applyMoves(test.current, moves); len(test.desired) > 0 && !reflect.DeepEqual(got, test.desired) {
+			t.Errorf("%s: moved to %v, want %v", test.name, got, test.desired)
+		}
+	}
+
+} // end TestQueueMoves
+
+func TestStayingSongs(t *testing.T) {
+
+	tests := []struct {
+		current []int
+		target  map[int]int
+		stays   []int
+	}{
+		{[]int{1, 2, 3}, map[int]int{1: 0, 2: 1, 3: 2}, []int{1, 2, 3}},
+		{[]int{3, 2, 1}, map[int]int{1: 0, 2: 1, 3: 2}, []int{1}},
+		{[]int{2, 1, 3}, map[int]int{1: 0, 2: 1, 3: 2}, []int{1, 3}},
+		{[]int{5, 1, 2, 6, 3}, map[int]int{1: 0, 2: 1, 3: 2, 5: 3, 6: 4}, []int{1, 2, 3}},
+	}
+
+	for _, test := range tests {
+		stays := stayingSongs(test.current, test.target)
+		if len(stays) != len(test.stays) {
+			t.Errorf("%v: %d songs stay, want %d", test.current, len(stays), len(test.stays))
+			continue
+		}
+		for _, id := range test.stays {
+			if !stays[id] {
+				t.Errorf("%v: song %d moves, want it to stay", test.current, id)
+			}
+		}
+	}
+
+} // end TestStayingSongs
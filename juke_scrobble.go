@@ -0,0 +1,372 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has Juke's offline scrobble journal and its exporters.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/idealeric/juke/log"
+	"github.com/idealeric/juke/ui"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scrobble journal settings:
+const (
+	SCROBBLE_FILENAME          = "scrobbles"          // journal of every song played (in the data directory)
+	SCROBBLE_EXPORTED_FILENAME = "scrobbles.exported" // newest start time exported, per format
+	SCROBBLE_CLIENT            = "Juke 0.3a"
+	LISTENBRAINZ_MAX_LISTENS   = 1000 // ListenBrainz refuses imports larger than this
+)
+
+// scrobbleEntry is a single song played, as stored in the journal.
+type scrobbleEntry struct {
+	started  time.Time
+	duration int
+	listened bool // false means the song was skipped
+	track    string
+	mbid     string
+	file     string
+	artist   string
+	album    string
+	title    string
+}
+
+// key identifies an entry, so that the same play is never exported twice.
+func (entry *scrobbleEntry) key() string {
+
+	return strconv.FormatInt(entry.started.Unix(), 10) + "\t" + entry.file
+
+} // end key
+
+// appendScrobble writes a played song to the end of the scrobble journal.
+func appendScrobble(entry *scrobbleEntry) {
+
+	filename := jukeDataFilename(SCROBBLE_FILENAME)
+	if filename == "" {
+		return
+	}
+
+	journalFile, errOpen := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if errOpen != nil {
+		log.ErrorReport("appendScrobble()", "Could not open scrobble journal ("+errOpen.Error()+").")
+		return
+	}
+	defer journalFile.Close()
+
+	rating := "S"
+	if entry.listened {
+		rating = "L"
+	}
+	// MPD may report the track as "3/12", only the number is kept.
+	line := strings.Join([]string{
+		strconv.FormatInt(entry.started.Unix(), 10),
+		strconv.Itoa(entry.duration),
+		rating,
+		historyField(strings.SplitN(entry.track, "/", 2)[0]),
+		historyField(entry.mbid),
+		historyField(entry.file),
+		historyField(entry.artist),
+		historyField(entry.album),
+		historyField(entry.title)}, "\t")
+	if _, errWrite := journalFile.WriteString(line + "\n"); errWrite != nil {
+		log.ErrorReport("appendScrobble()", "Could not write to scrobble journal ("+errWrite.Error()+").")
+	}
+
+} // end appendScrobble
+
+// readScrobbles reads the journal, oldest first, dropping duplicate entries.
+func readScrobbles() []*scrobbleEntry {
+
+	var entries []*scrobbleEntry
+
+	filename := jukeDataFilename(SCROBBLE_FILENAME)
+	if filename == "" {
+		return entries
+	}
+
+	journalFile, errOpen := os.Open(filename)
+	if errOpen != nil {
+		if !os.IsNotExist(errOpen) {
+			log.ErrorReport("readScrobbles()", "Could not open scrobble journal ("+errOpen.Error()+").")
+		}
+		return entries
+	}
+	defer journalFile.Close()
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(journalFile)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 9 {
+			log.ErrorReport("readScrobbles()", "Skipping malformed journal line.")
+			continue
+		}
+		started, errStarted := strconv.ParseInt(fields[0], 10, 64)
+		duration, errDuration := strconv.Atoi(fields[1])
+		if errStarted != nil || errDuration != nil {
+			log.ErrorReport("readScrobbles()", "Skipping journal line with a bad time or duration.")
+			continue
+		}
+		entry := &scrobbleEntry{
+			started:  time.Unix(started, 0),
+			duration: duration,
+			listened: fields[2] == "L",
+			track:    fields[3],
+			mbid:     fields[4],
+			file:     fields[5],
+			artist:   fields[6],
+			album:    fields[7],
+			title:    fields[8]}
+		if !seen[entry.key()] {
+			seen[entry.key()] = true
+			entries = append(entries, entry)
+		}
+	}
+	if errScan := scanner.Err(); errScan != nil {
+		log.ErrorReport("readScrobbles()", "Could not read scrobble journal ("+errScan.Error()+").")
+	}
+
+	return entries
+
+} // end readScrobbles
+
+// scrobbleFormatName is the name a format is kept under in the exported file.
+func scrobbleFormatName(format ui.ScrobbleFormat) string {
+
+	if format == ui.SCROBBLE_LISTENBRAINZ {
+		return "listenbrainz"
+	}
+	return "rockbox"
+
+} // end scrobbleFormatName
+
+// readScrobblesExported returns, per format, the start time of the newest
+// entry that has already been exported.
+func readScrobblesExported() map[string]int64 {
+
+	exported := make(map[string]int64)
+
+	filename := jukeDataFilename(SCROBBLE_EXPORTED_FILENAME)
+	if filename == "" {
+		return exported
+	}
+
+	exportedFile, errOpen := os.Open(filename)
+	if errOpen != nil {
+		if !os.IsNotExist(errOpen) {
+			log.ErrorReport("readScrobblesExported()", "Could not open exported file ("+errOpen.Error()+").")
+		}
+		return exported
+	}
+	defer exportedFile.Close()
+
+	scanner := bufio.NewScanner(exportedFile)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			if when, errWhen := strconv.ParseInt(fields[1], 10, 64); errWhen == nil {
+				exported[fields[0]] = when
+			}
+		}
+	}
+
+	return exported
+
+} // end readScrobblesExported
+
+// writeScrobblesExported saves the per format start times of the newest
+// exported entries.
+func writeScrobblesExported(exported map[string]int64) error {
+
+	filename := jukeDataFilename(SCROBBLE_EXPORTED_FILENAME)
+	if filename == "" {
+		return fmt.Errorf("no data directory")
+	}
+
+	contents := ""
+	for format, when := range exported {
+		contents += format + " " + strconv.FormatInt(when, 10) + "\n"
+	}
+	return os.WriteFile(filename, []byte(contents), 0644)
+
+} // end writeScrobblesExported
+
+// writeRockboxLog writes entries in the Audioscrobbler 1.1 (Rockbox) format.
+func writeRockboxLog(filename string, entries []*scrobbleEntry) error {
+
+	logFile, errCreate := os.Create(filename)
+	if errCreate != nil {
+		return errCreate
+	}
+	defer logFile.Close()
+
+	out := bufio.NewWriter(logFile)
+	fmt.Fprintf(out, "#AUDIOSCROBBLER/1.1\n#TZ/UTC\n#CLIENT/%s\n", SCROBBLE_CLIENT)
+	for _, entry := range entries {
+		rating := "S"
+		if entry.listened {
+			rating = "L"
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%d\t%s\t%d\t%s\n",
+			entry.artist, entry.album, entry.title, entry.track,
+			entry.duration, rating, entry.started.Unix(), entry.mbid)
+	}
+	return out.Flush()
+
+} // end writeRockboxLog
+
+// Types for the ListenBrainz import payload:
+type listenBrainzAdditional struct {
+	DurationMs       int    `json:"duration_ms,omitempty"`
+	TrackNumber      string `json:"tracknumber,omitempty"`
+	RecordingMBID    string `json:"recording_mbid,omitempty"`
+	MediaPlayer      string `json:"media_player"`
+	SubmissionClient string `json:"submission_client"`
+}
+
+type listenBrainzMetadata struct {
+	ArtistName     string                 `json:"artist_name"`
+	TrackName      string                 `json:"track_name"`
+	ReleaseName    string                 `json:"release_name,omitempty"`
+	AdditionalInfo listenBrainzAdditional `json:"additional_info"`
+}
+
+type listenBrainzListen struct {
+	ListenedAt    int64                `json:"listened_at"`
+	TrackMetadata listenBrainzMetadata `json:"track_metadata"`
+}
+
+type listenBrainzImport struct {
+	ListenType string               `json:"listen_type"`
+	Payload    []listenBrainzListen `json:"payload"`
+}
+
+// writeListenBrainzImports writes listened (not skipped) entries as
+// ListenBrainz import payloads. Since imports are limited in size, more
+// than one file may be written: filename, then filename-2, filename-3, etc.
+// The entries that made it into a file and the number of files written are
+// returned, even when a later file could not be written.
+func writeListenBrainzImports(filename string, entries []*scrobbleEntry) ([]*scrobbleEntry, int, error) {
+
+	var (
+		listens []listenBrainzListen
+		taken   []*scrobbleEntry
+	)
+	for _, entry := range entries {
+		// ListenBrainz only takes listens, and requires an artist and title.
+		if !entry.listened || entry.artist == "" || entry.title == "" {
+			continue
+		}
+		listens = append(listens, listenBrainzListen{
+			ListenedAt: entry.started.Unix(),
+			TrackMetadata: listenBrainzMetadata{
+				ArtistName:  entry.artist,
+				TrackName:   entry.title,
+				ReleaseName: entry.album,
+				AdditionalInfo: listenBrainzAdditional{
+					DurationMs:       entry.duration * 1000,
+					TrackNumber:      entry.track,
+					RecordingMBID:    entry.mbid,
+					MediaPlayer:      "MPD",
+					SubmissionClient: SCROBBLE_CLIENT}}})
+		taken = append(taken, entry)
+	}
+
+	ext := ""
+	base := filename
+	if dot := strings.LastIndex(filename, "."); dot > strings.LastIndex(filename, "/") {
+		base, ext = filename[:dot], filename[dot:]
+	}
+
+	files := 0
+	for start := 0; start < len(listens); start += LISTENBRAINZ_MAX_LISTENS {
+		end := start + LISTENBRAINZ_MAX_LISTENS
+		if end > len(listens) {
+			end = len(listens)
+		}
+		partName := filename
+		if files > 0 {
+			partName = base + "-" + strconv.Itoa(files+1) + ext
+		}
+		payload, errMarshal := json.MarshalIndent(&listenBrainzImport{ListenType: "import", Payload: listens[start:end]}, "", "  ")
+		if errMarshal != nil {
+			return taken[:start], files, errMarshal
+		}
+		if errWrite := os.WriteFile(partName, payload, 0644); errWrite != nil {
+			return taken[:start], files, errWrite
+		}
+		files++
+	}
+
+	return taken, files, nil
+
+} // end writeListenBrainzImports
+
+// exportScrobbles writes every journal entry that has not yet been exported
+// in the given format to filename, and then remembers what was exported.
+// Only entries that were actually written are remembered, so that nothing
+// is passed over that a later export could still write.
+func exportScrobbles(format ui.ScrobbleFormat, filename string) {
+
+	exported := readScrobblesExported()
+	formatName := scrobbleFormatName(format)
+
+	var pending []*scrobbleEntry
+	for _, entry := range readScrobbles() {
+		if entry.started.Unix() > exported[formatName] {
+			pending = append(pending, entry)
+		}
+	}
+
+	if len(pending) == 0 {
+		ui.ShowMessage("There are no new scrobbles to export.")
+		return
+	}
+
+	var (
+		written   []*scrobbleEntry
+		errExport error
+	)
+	message := ""
+	if format == ui.SCROBBLE_LISTENBRAINZ {
+		var files int
+		written, files, errExport = writeListenBrainzImports(filename, pending)
+		if len(written) == 0 && errExport == nil {
+			ui.ShowMessage("There are no new listens ListenBrainz can import.")
+			return
+		}
+		message = "Exported " + strconv.Itoa(len(written)) + " plays to " + strconv.Itoa(files) + " ListenBrainz import file(s)."
+	} else {
+		if errExport = writeRockboxLog(filename, pending); errExport == nil {
+			written = pending
+		}
+		message = "Exported " + strconv.Itoa(len(written)) + " plays to " + filename + "."
+	}
+
+	for _, entry := range written {
+		if entry.started.Unix() > exported[formatName] {
+			exported[formatName] = entry.started.Unix()
+		}
+	}
+	if len(written) > 0 {
+		if errSave := writeScrobblesExported(exported); errSave != nil {
+			log.ErrorReport("exportScrobbles()", "Could not remember exported scrobbles ("+errSave.Error()+").")
+		}
+	}
+
+	if errExport != nil {
+		log.ErrorReport("exportScrobbles()", "Could not export scrobbles ("+errExport.Error()+").")
+		ui.ShowMessage("Could not export scrobbles (" + errExport.Error() + ").")
+		return
+	}
+	ui.ShowMessage(message)
+
+} // end exportScrobbles
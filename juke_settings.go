@@ -0,0 +1,83 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has the playback settings (crossfade, MixRamp and
+ReplayGain).
+*/
+
+package main
+
+import (
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/log"
+	"github.com/idealeric/juke/ui"
+	"strconv"
+)
+
+// playbackSettings keeps the settings window in line with the server. The
+// ReplayGain mode is not part of MPD's status, so it is only asked for on
+// connecting and on changing it; the rest comes with every poll.
+type playbackSettings struct {
+	replayGain string
+	shown      ui.PlaybackSettings
+}
+
+// refresh asks the server for its ReplayGain mode.
+func (ps *playbackSettings) refresh(mpdConnection *mpd.Client) {
+
+	replayGain, errReplayGain := mpdConnection.Command("replay_gain_status").Attrs()
+	if errReplayGain != nil {
+		log.ErrorReport("playbackSettings.refresh()", "Could not establish the ReplayGain mode ("+errReplayGain.Error()+").")
+		return
+	}
+	ps.replayGain = replayGain["replay_gain_mode"]
+
+} // end refresh
+
+// show updates the settings window from a status, if anything has changed
+// (so that a setting being typed in is not overwritten on every poll).
+func (ps *playbackSettings) show(status mpd.Attrs) {
+
+	settings := ui.PlaybackSettings{MixRampDelay: -1, ReplayGain: ps.replayGain}
+	// MPD leaves out crossfade when off, and the MixRamp delay when disabled.
+	if crossfade, errCrossfade := strconv.Atoi(status["xfade"]); errCrossfade == nil {
+		settings.Crossfade = crossfade
+	}
+	if mixRampDB, errDB := strconv.ParseFloat(status["mixrampdb"], 64); errDB == nil {
+		settings.MixRampDB = mixRampDB
+	}
+	if mixRampDelay, errDelay := strconv.ParseFloat(status["mixrampdelay"], 64); errDelay == nil && mixRampDelay >= 0 {
+		settings.MixRampDelay = mixRampDelay
+	}
+
+	if settings != ps.shown {
+		ps.shown = settings
+		ui.SetPlaybackSettings(&settings)
+	}
+
+} // end show
+
+// change applies a setting changed in the settings window.
+func (ps *playbackSettings) change(mpdConnection *mpd.Client, setting ui.PlaybackSetting, value float64) error {
+
+	switch setting {
+	case ui.SETTING_CROSSFADE:
+		return mpdConnection.Command("crossfade %d", int(value)).OK()
+	case ui.SETTING_MIXRAMP_DB:
+		return mpdConnection.Command("mixrampdb %s", strconv.FormatFloat(value, 'f', 1, 64)).OK()
+	case ui.SETTING_MIXRAMP_DELAY:
+		// MixRamp is turned off by a delay of "nan".
+		if value < 0 {
+			return mpdConnection.Command("mixrampdelay nan").OK()
+		}
+		return mpdConnection.Command("mixrampdelay %s", strconv.FormatFloat(value, 'f', 1, 64)).OK()
+	case ui.SETTING_REPLAY_GAIN:
+		mode := ui.REPLAY_GAIN_MODES[int(value)]
+		if errMode := mpdConnection.Command("replay_gain_mode %s", mode).OK(); errMode != nil {
+			return errMode
+		}
+		ps.replayGain = mode
+	}
+	return nil
+
+} // end change
@@ -0,0 +1,293 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has Juke's rule-based smart playlists.
+*/
+
+package main
+
+import (
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/config"
+	"github.com/idealeric/juke/log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smartContext is everything (besides the song itself) that rules may look at.
+type smartContext struct {
+	ratings    map[string]int       // by URI, from stickers
+	plays      map[string]int       // by URI, from the history
+	lastPlayed map[string]time.Time // by URI, from the history
+	now        time.Time
+}
+
+// newSmartContext gathers ratings and the listening history.
+func newSmartContext(mpdConnection *mpd.Client, stickers *stickerSupport) *smartContext {
+
+	context := &smartContext{
+		ratings:    stickers.ratings(mpdConnection),
+		plays:      make(map[string]int),
+		lastPlayed: make(map[string]time.Time),
+		now:        time.Now()}
+
+	for _, entry := range readHistory() {
+		context.plays[entry.file]++
+		if entry.when.After(context.lastPlayed[entry.file]) {
+			context.lastPlayed[entry.file] = entry.when
+		}
+	}
+
+	return context
+
+} // end newSmartContext
+
+// songTag looks up a tag on a song without regard to case, since MPD
+// reports "AlbumArtist" but nobody wants to type that in their config.
+func songTag(song mpd.Attrs, field string) string {
+
+	if value, exists := song[field]; exists {
+		return value
+	}
+	for key, value := range song {
+		if strings.EqualFold(key, field) {
+			return value
+		}
+	}
+	return ""
+
+} // end songTag
+
+// smartField returns the value of a rule's field for a song.
+func (context *smartContext) smartField(song mpd.Attrs, field string) string {
+
+	switch strings.ToLower(field) {
+	case "rating":
+		return strconv.Itoa(context.ratings[song["file"]])
+	case "plays":
+		return strconv.Itoa(context.plays[song["file"]])
+	case "lastplayed":
+		if last, played := context.lastPlayed[song["file"]]; played {
+			return strconv.FormatInt(last.Unix(), 10)
+		}
+		return ""
+	}
+	return songTag(song, field)
+
+} // end smartField
+
+// compareNumbers applies a numeric operator.
+func compareNumbers(a float64, op string, b float64) bool {
+
+	switch op {
+	case "=", "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+
+} // end compareNumbers
+
+// matches decides whether a song satisfies a single rule.
+func (context *smartContext) matches(song mpd.Attrs, rule *config.SmartRule) bool {
+
+	op := strings.ToLower(rule.Op)
+
+	if op == "inlast" || op == "notinlast" {
+		days, errDays := strconv.Atoi(rule.Value)
+		if errDays != nil {
+			return false
+		}
+		last, played := context.lastPlayed[song["file"]]
+		recent := played && context.now.Sub(last) < time.Duration(days)*24*time.Hour
+		return recent == (op == "inlast")
+	}
+
+	value := context.smartField(song, rule.Field)
+	switch op {
+	case "is":
+		return strings.EqualFold(value, rule.Value)
+	case "isnot":
+		return !strings.EqualFold(value, rule.Value)
+	case "contains":
+		return strings.Contains(strings.ToLower(value), strings.ToLower(rule.Value))
+	case "notcontains":
+		return !strings.Contains(strings.ToLower(value), strings.ToLower(rule.Value))
+	}
+
+	// Everything else is numeric. Tags such as "3/12" (track) or
+	// "1999-05-01" (date) are compared on their leading number.
+	a, errA := strconv.ParseFloat(leadingNumber(value), 64)
+	b, errB := strconv.ParseFloat(rule.Value, 64)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return compareNumbers(a, op, b)
+
+} // end matches
+
+// leadingNumber returns the leading run of digits (and a decimal point) of s.
+func leadingNumber(s string) string {
+
+	s = strings.TrimSpace(s)
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.') {
+		end++
+	}
+	return s[:end]
+
+} // end leadingNumber
+
+// selects decides whether a song belongs in a smart playlist.
+func (context *smartContext) selects(song mpd.Attrs, playlist *config.SmartPlaylist) bool {
+
+	matchAny := strings.EqualFold(playlist.Match, "any")
+	for i := range playlist.Rules {
+		if context.matches(song, &playlist.Rules[i]) == matchAny {
+			return matchAny
+		}
+	}
+	return !matchAny
+
+} // end selects
+
+// compareSmartValues orders two field values: numerically when both are
+// numbers (ratings, plays, durations), otherwise naturally, so that runs of
+// digits in dates ("1999-05-01") and tracks ("3/12") compare as numbers.
+func compareSmartValues(a, b string) int {
+
+	numberA, errA := strconv.ParseFloat(a, 64)
+	numberB, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		if numberA < numberB {
+			return -1
+		} else if numberA > numberB {
+			return 1
+		}
+		return 0
+	}
+	return compareNatural(strings.ToLower(a), strings.ToLower(b))
+
+} // end compareSmartValues
+
+// smartOrder splits a playlist's order into the field and whether it is
+// descending ("date desc").
+func smartOrder(order string) (string, bool) {
+
+	words := strings.Fields(order)
+	if len(words) == 2 && (strings.EqualFold(words[1], "desc") || strings.EqualFold(words[1], "asc")) {
+		return words[0], strings.EqualFold(words[1], "desc")
+	}
+	return strings.TrimSpace(order), false
+
+} // end smartOrder
+
+// findSmartPlaylist looks up a smart playlist in the config by name.
+func findSmartPlaylist(name string) *config.SmartPlaylist {
+
+	playlists := config.Get().SmartPlaylists
+	for i := range playlists {
+		if playlists[i].Name == name {
+			return &playlists[i]
+		}
+	}
+	return nil
+
+} // end findSmartPlaylist
+
+// evaluateSmartPlaylist returns the URIs of the songs that a smart
+// playlist currently selects, in the playlist's order and within its limit.
+// The library is only listed again when MPD's database has changed.
+func evaluateSmartPlaylist(mpdConnection *mpd.Client, stickers *stickerSupport, library *libraryCache, playlist *config.SmartPlaylist) ([]string, error) {
+
+	if errLibrary := library.refresh(mpdConnection); errLibrary != nil {
+		return nil, errLibrary
+	}
+
+	context := newSmartContext(mpdConnection, stickers)
+	var songs []mpd.Attrs
+	for _, song := range library.songs {
+		if context.selects(song, playlist) {
+			songs = append(songs, song)
+		}
+	}
+
+	if field, descending := smartOrder(playlist.Order); strings.EqualFold(field, "random") {
+		rand.Shuffle(len(songs), func(i, j int) {
+			songs[i], songs[j] = songs[j], songs[i]
+		})
+	} else if field != "" {
+		values := make(map[string]string, len(songs))
+		for _, song := range songs {
+			values[song["file"]] = context.smartField(song, field)
+		}
+		sort.SliceStable(songs, func(i, j int) bool {
+			order := compareSmartValues(values[songs[i]["file"]], values[songs[j]["file"]])
+			if descending {
+				return order > 0
+			}
+			return order < 0
+		})
+	}
+
+	if playlist.Limit > 0 && len(songs) > playlist.Limit {
+		songs = songs[:playlist.Limit]
+	}
+
+	uris := make([]string, len(songs))
+	for i, song := range songs {
+		uris[i] = song["file"]
+	}
+	return uris, nil
+
+} // end evaluateSmartPlaylist
+
+// materializeSmartPlaylist evaluates a smart playlist and either appends
+// the songs to the current playlist or saves them as a stored playlist
+// of the same name (replacing it).
+func materializeSmartPlaylist(mpdConnection *mpd.Client, stickers *stickerSupport, library *libraryCache, name string, save bool) {
+
+	playlist := findSmartPlaylist(name)
+	if playlist == nil {
+		log.ErrorReport("materializeSmartPlaylist()", "No smart playlist named "+name+".")
+		return
+	}
+
+	uris, errEval := evaluateSmartPlaylist(mpdConnection, stickers, library, playlist)
+	if errEval != nil {
+		log.ErrorReport("materializeSmartPlaylist()", "Could not list the library ("+errEval.Error()+").")
+		return
+	}
+
+	if save {
+		// Removing a stored playlist that does not exist yet is an error, but a harmless one.
+		mpdConnection.PlaylistRemove(name)
+	}
+
+	cmdList := mpdConnection.BeginCommandList()
+	for _, uri := range uris {
+		if save {
+			cmdList.PlaylistAdd(name, uri)
+		} else {
+			cmdList.Add(uri)
+		}
+	}
+	if cmdErr := cmdList.End(); cmdErr != nil {
+		log.ErrorReport("materializeSmartPlaylist()", "Could not end the command list ("+cmdErr.Error()+").")
+	} else {
+		log.MessageReport("materializeSmartPlaylist()", strconv.Itoa(len(uris))+" songs from smart playlist "+name+".")
+	}
+
+} // end materializeSmartPlaylist
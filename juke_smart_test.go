@@ -0,0 +1,153 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file tests Juke's smart playlist rules and ordering.
+*/
+
+package main
+
+import (
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/config"
+	"testing"
+	"time"
+)
+
+func TestSmartRuleMatches(t *testing.T) {
+
+	now := time.Now()
+	context := &smartContext{
+		ratings:    map[string]int{"jazz.flac": 4},
+		plays:      map[string]int{"jazz.flac": 12},
+		lastPlayed: map[string]time.Time{"jazz.flac": now.Add(-3 * 24 * time.Hour)},
+		now:        now}
+	song := mpd.Attrs{
+		"file":        "jazz.flac",
+		"Genre":       "Jazz",
+		"AlbumArtist": "Miles Davis",
+		"Track":       "3/12",
+		"Date":        "1959-08-17"}
+
+	tests := []struct {
+		field, op, value string
+		want             bool
+	}{
+		{"genre", "is", "jazz", true},
+		{"genre", "is", "Rock", false},
+		{"genre", "isnot", "Rock", true},
+		{"genre", "isnot", "JAZZ", false},
+		{"albumartist", "contains", "davis", true},
+		{"albumartist", "notcontains", "davis", false},
+		{"rating", ">=", "4", true},
+		{"rating", ">", "4", false},
+		{"rating", "=", "4", true},
+		{"rating", "==", "4", true},
+		{"rating", "!=", "4", false},
+		{"plays", "<", "20", true},
+		{"plays", "<=", "11", false},
+		{"track", "=", "3", true},
+		{"date", ">=", "1959", true},
+		{"date", "<", "1950", false},
+		{"genre", ">", "1", false}, // not a number
+		{"rating", ">", "many", false},
+		{"rating", "~", "4", false}, // unknown operator
+		{"lastplayed", "inlast", "7", true},
+		{"lastplayed", "inlast", "2", false},
+		{"lastplayed", "notinlast", "2", true},
+		{"lastplayed", "notinlast", "seven", false},
+	}
+
+	for _, test := range tests {
+		rule := &config.SmartRule{Field: test.field, Op: test.op, Value: test.value}
+		if got := context.matches(song, rule); got != test.want {
+			t.Errorf("%s %s %s: got %v, want %v", test.field, test.op, test.value, got, test.want)
+		}
+	}
+
+	unplayed := mpd.Attrs{"file": "new.flac"}
+	if context.matches(unplayed, &config.SmartRule{Field: "lastplayed", Op: "inlast", Value: "7"}) {
+		t.Errorf("an unplayed song was played in the last 7 days")
+	}
+	if !context.matches(unplayed, &config.SmartRule{Field: "lastplayed", Op: "notinlast", Value: "7"}) {
+		t.Errorf("an unplayed song was not left out of the last 7 days")
+	}
+
+} // end TestSmartRuleMatches
+
+func TestSmartPlaylistSelects(t *testing.T) {
+
+	context := &smartContext{ratings: map[string]int{}, plays: map[string]int{}, lastPlayed: map[string]time.Time{}, now: time.Now()}
+	song := mpd.Attrs{"file": "a.flac", "Genre": "Jazz", "Artist": "Monk"}
+	rules := []config.SmartRule{
+		{Field: "genre", Op: "is", Value: "Jazz"},
+		{Field: "artist", Op: "is", Value: "Coltrane"}}
+
+	tests := []struct {
+		match string
+		rules []config.SmartRule
+		want  bool
+	}{
+		{"", rules, false},
+		{"all", rules, false},
+		{"any", rules, true},
+		{"ANY", rules[1:], false},
+		{"all", nil, true},
+		{"any", nil, false},
+	}
+
+	for _, test := range tests {
+		playlist := &config.SmartPlaylist{Match: test.match, Rules: test.rules}
+		if got := context.selects(song, playlist); got != test.want {
+			t.Errorf("match %q of %d rules: got %v, want %v", test.match, len(test.rules), got, test.want)
+		}
+	}
+
+} // end TestSmartPlaylistSelects
+
+func TestCompareSmartValues(t *testing.T) {
+
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"9", "10", -1},
+		{"245.5", "245.123", 1},
+		{"4", "4.0", 0},
+		{"3/12", "10/12", -1},
+		{"1999-05-01", "1999-12-01", -1},
+		{"2001", "1999-12-01", 1},
+		{"Abba", "abba", 0},
+		{"", "1", -1},
+	}
+
+	for _, test := range tests {
+		if got := compareSmartValues(test.a, test.b); got != test.want {
+			t.Errorf("compareSmartValues(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+
+} // end TestCompareSmartValues
+
+func TestSmartOrder(t *testing.T) {
+
+	tests := []struct {
+		order, field string
+		descending   bool
+	}{
+		{"", "", false},
+		{"random", "random", false},
+		{"date", "date", false},
+		{"date desc", "date", true},
+		{"rating DESC", "rating", true},
+		{"plays asc", "plays", false},
+		{" track ", "track", false},
+	}
+
+	for _, test := range tests {
+		field, descending := smartOrder(test.order)
+		if field != test.field || descending != test.descending {
+			t.Errorf("smartOrder(%q) = %q, %v, want %q, %v", test.order, field, descending, test.field, test.descending)
+		}
+	}
+
+} // end TestSmartOrder
@@ -0,0 +1,257 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has the sorting of the current playlist, which is done
+on the server from the songs' full tags.
+*/
+
+package main
+
+import (
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/config"
+	"github.com/idealeric/juke/ui"
+	"golang.org/x/text/unicode/norm"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sortedSong is a song of the current playlist with what it is sorted by.
+type sortedSong struct {
+	id     int
+	tags   mpd.Attrs
+	rating int
+	text   map[ui.SortKey]string // collation text of each text key
+}
+
+// Letters that decomposing leaves whole, folded to plain ones by hand.
+var unfoldedLetters = map[rune]string{
+	'æ': "ae", 'œ': "oe", 'ß': "ss", 'ø': "o", 'đ': "d", 'ð': "d",
+	'ł': "l", 'ŀ': "l", 'þ': "th", 'ħ': "h", 'ı': "i"}
+
+// collationText turns a tag into the text it sorts by: lower case, accents
+// folded, punctuation dropped and (if so configured) leading articles left
+// out. Accents are folded by decomposing every letter (compatibility
+// forms, such as ligatures and full width digits, included) and dropping
+// the marks, so that accented names sort with their unaccented letters
+// rather than after Z.
+func collationText(tag string) string {
+
+	var text []rune
+	space := true // no leading space
+	for _, letter := range norm.NFKD.String(strings.ToLower(tag)) {
+		if unicode.Is(unicode.Mn, letter) {
+			continue
+		}
+		if folded, exists := unfoldedLetters[letter]; exists {
+			text = append(text, []rune(folded)...)
+			space = false
+		} else if unicode.IsLetter(letter) || unicode.IsDigit(letter) {
+			text = append(text, letter)
+			space = false
+		} else if unicode.IsSpace(letter) && !space {
+			text = append(text, ' ')
+			space = true
+		}
+	}
+	collated := strings.TrimSpace(string(text))
+
+	sorting := config.Get().Sorting
+	if sorting.IgnoreArticles {
+		for _, article := range sorting.Articles {
+			prefix := strings.ToLower(article) + " "
+			if strings.HasPrefix(collated, prefix) && len(collated) > len(prefix) {
+				return collated[len(prefix):]
+			}
+		}
+	}
+	return collated
+
+} // end collationText
+
+// compareNatural compares collation texts, runs of digits as numbers (so
+// "track 2" comes before "track 10").
+func compareNatural(a, b string) int {
+
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			numberA, numberB := digitRun(a), digitRun(b)
+			a, b = a[len(numberA):], b[len(numberB):]
+			// Leading zeros aside, the longer run is the larger number.
+			trimmedA, trimmedB := strings.TrimLeft(numberA, "0"), strings.TrimLeft(numberB, "0")
+			if order := compareInts(len(trimmedA), len(trimmedB)); order != 0 {
+				return order
+			}
+			if order := strings.Compare(trimmedA, trimmedB); order != 0 {
+				return order
+			}
+			continue
+		}
+		runeA, sizeA := utf8.DecodeRuneInString(a)
+		runeB, sizeB := utf8.DecodeRuneInString(b)
+		if order := compareInts(int(runeA), int(runeB)); order != 0 {
+			return order
+		}
+		a, b = a[sizeA:], b[sizeB:]
+	}
+	return compareInts(len(a), len(b))
+
+} // end compareNatural
+
+// isDigit tells whether a byte is an ASCII digit.
+func isDigit(c byte) bool {
+
+	return c >= '0' && c <= '9'
+
+} // end isDigit
+
+// digitRun returns the digits s starts with.
+func digitRun(s string) string {
+
+	end := 0
+	for end < len(s) && isDigit(s[end]) {
+		end++
+	}
+	return s[:end]
+
+} // end digitRun
+
+// tagNumber reads the number a tag starts with ("3/12" is 3), songs
+// without one go first.
+func tagNumber(tag string) int {
+
+	number, errNumber := strconv.Atoi(leadingNumber(tag))
+	if errNumber != nil {
+		return -1
+	}
+	return number
+
+} // end tagNumber
+
+// compareInts compares two numbers, -1, 0 or 1 as a is less, equal or more.
+func compareInts(a, b int) int {
+
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+
+} // end compareInts
+
+// sortTag returns the tag a text key sorts by, preferring the tag's sort
+// version (ArtistSort for Artist, ...) when the song has one.
+func sortTag(tags mpd.Attrs, key ui.SortKey) string {
+
+	var names []string
+	switch key {
+	case ui.SORT_TITLE:
+		names = []string{"TitleSort", "Title"}
+	case ui.SORT_ARTIST:
+		names = []string{"ArtistSort", "Artist"}
+	case ui.SORT_ALBUM_ARTIST:
+		// Songs without an album artist sort by their artist.
+		names = []string{"AlbumArtistSort", "AlbumArtist", "ArtistSort", "Artist"}
+	case ui.SORT_ALBUM:
+		names = []string{"AlbumSort", "Album"}
+	case ui.SORT_GENRE:
+		names = []string{"Genre"}
+	case ui.SORT_COMPOSER:
+		names = []string{"ComposerSort", "Composer"}
+	case ui.SORT_PERFORMER:
+		names = []string{"Performer"}
+	case ui.SORT_FILE:
+		names = []string{"file"}
+	}
+	for _, name := range names {
+		if tag, exists := tags[name]; exists {
+			return tag
+		}
+	}
+	return ""
+
+} // end sortTag
+
+// compareSongs compares two songs on one key.
+func compareSongs(a, b *sortedSong, key ui.SortKey) int {
+
+	switch key {
+	case ui.SORT_DATE:
+		// Dates are YYYY, YYYY-MM or YYYY-MM-DD, their punctuation counts.
+		return compareNatural(a.tags["Date"], b.tags["Date"])
+	case ui.SORT_DURATION:
+		return compareInts(tagNumber(a.tags["Time"]), tagNumber(b.tags["Time"]))
+	case ui.SORT_DISC:
+		return compareInts(tagNumber(a.tags["Disc"]), tagNumber(b.tags["Disc"]))
+	case ui.SORT_TRACK:
+		return compareInts(tagNumber(a.tags["Track"]), tagNumber(b.tags["Track"]))
+	case ui.SORT_RATING:
+		return compareInts(a.rating, b.rating)
+	case ui.SORT_PRIORITY:
+		return compareInts(tagNumber(a.tags["Prio"]), tagNumber(b.tags["Prio"]))
+	}
+	return compareNatural(a.text[key], b.text[key])
+
+} // end compareSongs
+
+// sortQueue sorts the current playlist by some keys, moving as few songs as
+// possible. Songs equal on every key keep their order. The new order and
+// the playlist version after the moves are returned.
+func sortQueue(mpdConnection *mpd.Client, stickers *stickerSupport, keys []ui.SortKey, descending bool) ([]int, int, error) {
+
+	queue, errQueue := mpdConnection.PlaylistInfo(-1, -1)
+	if errQueue != nil {
+		return nil, -1, errQueue
+	}
+	ratings := stickers.ratings(mpdConnection)
+
+	songs := make([]*sortedSong, 0, len(queue))
+	current := make([]int, 0, len(queue))
+	for _, tags := range queue {
+		if id, errId := strconv.Atoi(tags["Id"]); errId == nil {
+			song := &sortedSong{id: id, tags: tags, rating: ratings[tags["file"]], text: make(map[ui.SortKey]string)}
+			// Collating is the costly part, so it is done once per song.
+			for _, key := range keys {
+				song.text[key] = collationText(sortTag(tags, key))
+			}
+			songs = append(songs, song)
+			current = append(current, id)
+		}
+	}
+
+	sort.SliceStable(songs, func(i, j int) bool {
+		for _, key := range keys {
+			if order := compareSongs(songs[i], songs[j], key); order != 0 {
+				return (order < 0) != descending
+			}
+		}
+		return false
+	})
+	desired := make([]int, len(songs))
+	for i, song := range songs {
+		desired[i] = song.id
+	}
+
+	cmdList := mpdConnection.BeginCommandList()
+	reorderQueue(cmdList, current, desired)
+	// The status is read within the same command list, so that the
+	// version is the one right after the moves.
+	promisedStatus := cmdList.Status()
+	if errList := cmdList.End(); errList != nil {
+		return nil, -1, errList
+	}
+	status, errStatus := promisedStatus.Value()
+	if errStatus != nil {
+		return nil, -1, errStatus
+	}
+	version, errVersion := strconv.Atoi(status["playlist"])
+	if errVersion != nil {
+		return nil, -1, errVersion
+	}
+	return desired, version, nil
+
+} // end sortQueue
@@ -0,0 +1,105 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file tests the sorting of the current playlist.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestCompareNatural(t *testing.T) {
+
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "a", -1},
+		{"a", "", 1},
+		{"abc", "abd", -1},
+		{"track 2", "track 10", -1},
+		{"track 10", "track 2", 1},
+		{"track 10", "track 10", 0},
+		{"track 007", "track 7", 0},
+		{"track 007", "track 8", -1},
+		{"track 010", "track 9", 1},
+		{"0", "00", 0},
+		{"00", "1", -1},
+		{"disc 1 track 12", "disc 2 track 1", -1},
+		{"disc 1 track 12", "disc 1 track 2", 1},
+		{"2 becomes 1", "10 becomes 1", -1},
+		{"a1b2", "a1b10", -1},
+		{"a12", "a1b", 1},
+		{"99999999999999999999", "100000000000000000000", -1},
+		{"x9", "xa", -1},
+		{"é", "z", 1}, // runes beyond ASCII compare by code point
+	}
+
+	for _, test := range tests {
+		if got := compareNatural(test.a, test.b); got != test.want {
+			t.Errorf("compareNatural(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+
+} // end TestCompareNatural
+
+func TestTagNumber(t *testing.T) {
+
+	tests := []struct {
+		tag  string
+		want int
+	}{
+		{"3", 3},
+		{"3/12", 3},
+		{" 04", 4},
+		{"1999-05-01", 1999},
+		{"", -1},
+		{"side A", -1},
+	}
+
+	for _, test := range tests {
+		if got := tagNumber(test.tag); got != test.want {
+			t.Errorf("tagNumber(%q) = %d, want %d", test.tag, got, test.want)
+		}
+	}
+
+} // end TestTagNumber
+
+func TestCollationText(t *testing.T) {
+
+	tests := []struct {
+		tag, want string
+	}{
+		{"Abba", "abba"},
+		{"Björk", "bjork"},
+		{"Sigur Rós", "sigur ros"},
+		{"Mötley Crüe", "motley crue"},
+		{"Ștefan Bănică", "stefan banica"},
+		{"Țară", "tara"},
+		{"Ǎǎ", "aa"},
+		{"Erdős Ősz Űr", "erdos osz ur"},
+		{"Trịnh Công Sơn", "trinh cong son"},
+		{"Đàm Vĩnh Hưng", "dam vinh hung"},
+		{"Sæbø", "saebo"},
+		{"Œuvre", "oeuvre"},
+		{"Straße", "strasse"},
+		{"Łódź", "lodz"},
+		{"ﬁre", "fire"},
+		{"Track １２", "track 12"},
+		{"  AC/DC -- Live!  ", "acdc live"},
+		{"The Beatles", "beatles"},
+		{"A Tribe Called Quest", "tribe called quest"},
+		{"The", "the"},
+		{"Theory of a Deadman", "theory of a deadman"},
+	}
+
+	for _, test := range tests {
+		if got := collationText(test.tag); got != test.want {
+			t.Errorf("collationText(%q) = %q, want %q", test.tag, got, test.want)
+		}
+	}
+
+} // end TestCollationText
@@ -0,0 +1,152 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has Juke's use of MPD stickers (ratings and play counts).
+*/
+
+package main
+
+import (
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/config"
+	"github.com/idealeric/juke/log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sticker names (all on the "song" type):
+const (
+	STICKER_RATING      = "rating"
+	STICKER_PLAY_COUNT  = "playcount"
+	STICKER_SKIP_COUNT  = "skipcount"
+	STICKER_LAST_PLAYED = "lastplayed"
+)
+
+// stickerSupport tracks whether stickers can be used on the current
+// connection. They are only used when enabled in the config and the
+// server has a sticker database; the first sticker failure turns them
+// off until the next connection.
+type stickerSupport struct {
+	available bool
+}
+
+// detect checks a fresh connection for sticker support.
+func (ss *stickerSupport) detect(mpdConnection *mpd.Client) {
+
+	ss.available = false
+	if !config.Get().Stickers {
+		return
+	}
+
+	commands, errCommands := mpdConnection.Command("commands").Strings("command")
+	if errCommands != nil {
+		log.ErrorReport("stickerSupport.detect()", "Could not list MPD commands ("+errCommands.Error()+").")
+		return
+	}
+	for _, command := range commands {
+		if command == "sticker" {
+			ss.available = true
+			break
+		}
+	}
+	if !ss.available {
+		log.MessageReport("stickerSupport.detect()", "MPD has no sticker database, ratings and play counts are off.")
+	}
+
+} // end detect
+
+// failed reports a sticker error and turns stickers off.
+func (ss *stickerSupport) failed(where string, err error) {
+
+	log.ErrorReport(where, "Sticker command failed, ratings and play counts are off ("+err.Error()+").")
+	ss.available = false
+
+} // end failed
+
+// ratings returns the rating of every rated song, by URI.
+func (ss *stickerSupport) ratings(mpdConnection *mpd.Client) map[string]int {
+
+	ratings := make(map[string]int)
+	if !ss.available {
+		return ratings
+	}
+
+	found, errFind := mpdConnection.Command("sticker find song %s %s", "", STICKER_RATING).AttrsList("file")
+	if errFind != nil {
+		ss.failed("stickerSupport.ratings()", errFind)
+		return ratings
+	}
+	for _, attrs := range found {
+		// The sticker comes back as "name=value".
+		if value := strings.TrimPrefix(attrs["sticker"], STICKER_RATING+"="); value != attrs["sticker"] {
+			if rating, errRating := strconv.Atoi(value); errRating == nil {
+				ratings[attrs["file"]] = rating
+			}
+		}
+	}
+	return ratings
+
+} // end ratings
+
+// setRating rates a song (0 removes the rating).
+func (ss *stickerSupport) setRating(mpdConnection *mpd.Client, uri string, rating int) bool {
+
+	if !ss.available {
+		return false
+	}
+
+	if rating <= 0 {
+		// Deleting a sticker that does not exist is an error, but a harmless one.
+		mpdConnection.Command("sticker delete song %s %s", uri, STICKER_RATING).OK()
+		return true
+	}
+	if errSet := mpdConnection.Command("sticker set song %s %s %s", uri, STICKER_RATING, strconv.Itoa(rating)).OK(); errSet != nil {
+		ss.failed("stickerSupport.setRating()", errSet)
+		return false
+	}
+	return true
+
+} // end setRating
+
+// increment adds one to a counting sticker.
+func (ss *stickerSupport) increment(mpdConnection *mpd.Client, uri, name string) {
+
+	count := 0
+	// A song that has never been counted has no sticker, so a failed get
+	// simply means zero.
+	if attrs, errGet := mpdConnection.Command("sticker get song %s %s", uri, name).Attrs(); errGet == nil {
+		count, _ = strconv.Atoi(strings.TrimPrefix(attrs["sticker"], name+"="))
+	}
+	if errSet := mpdConnection.Command("sticker set song %s %s %s", uri, name, strconv.Itoa(count+1)).OK(); errSet != nil {
+		ss.failed("stickerSupport.increment()", errSet)
+	}
+
+} // end increment
+
+// played counts a song as played to the end and stamps the time.
+func (ss *stickerSupport) played(mpdConnection *mpd.Client, uri string) {
+
+	if !ss.available || uri == "" {
+		return
+	}
+
+	ss.increment(mpdConnection, uri, STICKER_PLAY_COUNT)
+	if ss.available {
+		if errSet := mpdConnection.Command("sticker set song %s %s %s", uri, STICKER_LAST_PLAYED, strconv.FormatInt(time.Now().Unix(), 10)).OK(); errSet != nil {
+			ss.failed("stickerSupport.played()", errSet)
+		}
+	}
+
+} // end played
+
+// skipped counts a song as skipped.
+func (ss *stickerSupport) skipped(mpdConnection *mpd.Client, uri string) {
+
+	if !ss.available || uri == "" {
+		return
+	}
+
+	ss.increment(mpdConnection, uri, STICKER_SKIP_COUNT)
+
+} // end skipped
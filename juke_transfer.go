@@ -0,0 +1,312 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has the moving of playback between server profiles:
+sending the queue and position elsewhere, and mirroring the queue.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/config"
+	"github.com/idealeric/juke/log"
+	"strconv"
+	"time"
+)
+
+// Song ID of a file that could not be added to another server.
+const TRANSFER_SKIPPED = -1
+
+// How long another server may take to answer before it is given up on.
+const TRANSFER_DIAL_TIMEOUT = 3 * time.Second
+
+// findServer looks up a server profile in the config by name.
+func findServer(name string) *config.Server {
+
+	servers := config.Get().Servers
+	for i := range servers {
+		if servers[i].Name == name {
+			return &servers[i]
+		}
+	}
+	return nil
+
+} // end findServer
+
+// queueFiles returns the URIs of every song in the current playlist.
+func queueFiles(mpdConnection *mpd.Client) ([]string, error) {
+
+	queue, errQueue := mpdConnection.PlaylistInfo(-1, -1)
+	if errQueue != nil {
+		return nil, errQueue
+	}
+	files := make([]string, len(queue))
+	for i, song := range queue {
+		files[i] = song["file"]
+	}
+	return files, nil
+
+} // end queueFiles
+
+// addFiles appends songs to the current playlist, which holds start songs
+// before them. They are added in a single command list. A song the other
+// server does not have fails the list (keeping the songs added before it),
+// in which case those are taken out again and the songs are added one at a
+// time, skipping the missing ones rather than failing the lot. The song ID
+// each file got is returned (TRANSFER_SKIPPED for those skipped), along
+// with the number of songs skipped.
+func addFiles(mpdConnection *mpd.Client, files []string, start int) ([]int, int, error) {
+
+	ids := make([]int, len(files))
+	cmdList := mpdConnection.BeginCommandList()
+	promised := make([]*mpd.PromisedId, len(files))
+	for i, file := range files {
+		promised[i] = cmdList.AddId(file, -1)
+	}
+	if cmdList.End() == nil {
+		for i := range promised {
+			id, errId := promised[i].Value()
+			if errId != nil {
+				return nil, 0, errId
+			}
+			ids[i] = id
+		}
+		return ids, 0, nil
+	}
+
+	queue, errQueue := queueFiles(mpdConnection)
+	if errQueue != nil {
+		return nil, 0, errQueue
+	}
+	if len(queue) > start {
+		if errDelete := mpdConnection.Delete(start, len(queue)); errDelete != nil {
+			return nil, 0, errDelete
+		}
+	}
+	skipped := 0
+	for i, file := range files {
+		if id, errAdd := mpdConnection.AddId(file, -1); errAdd != nil {
+			ids[i] = TRANSFER_SKIPPED
+			skipped++
+		} else {
+			ids[i] = id
+		}
+	}
+	return ids, skipped, nil
+
+} // end addFiles
+
+// sendPlayback takes the current playlist and position of mpdConnection to
+// the named profile. The other server is brought in line on a goroutine of
+// its own, so that a slow or unreachable one does not hold update() (and
+// the UI) up; it tells update() how that went with a PLAYBACK_SENT request
+// (which also stops the source, if asked to), unless done is closed first.
+func sendPlayback(mpdConnection *mpd.Client, updateChannel chan *jukeRequest, done chan bool, name string, stopSource bool) error {
+
+	server := findServer(name)
+	if server == nil {
+		return fmt.Errorf("no server named %s", name)
+	}
+
+	status, errStatus := mpdConnection.Status()
+	if errStatus != nil {
+		return errStatus
+	}
+	files, errFiles := queueFiles(mpdConnection)
+	if errFiles != nil {
+		return errFiles
+	}
+
+	// The profile is copied, as the config is only for update() to touch.
+	target := *server
+	go func() {
+		errSend := playbackTo(&target, status, files)
+		select {
+		case updateChannel <- &jukeRequest{state: PLAYBACK_SENT, name: name, save: stopSource, err: errSend}:
+		case <-done:
+		}
+	}()
+	return nil
+
+} // end sendPlayback
+
+// playbackTo replaces the current playlist of a profile with files and
+// resumes the current song of status there at the same position (and in
+// the same state).
+func playbackTo(server *config.Server, status mpd.Attrs, files []string) error {
+
+	target, errDial := dialServerTimeout(server, TRANSFER_DIAL_TIMEOUT)
+	if errDial != nil {
+		return errDial
+	}
+	defer target.Close()
+
+	if errClear := target.Clear(); errClear != nil {
+		return errClear
+	}
+	ids, skipped, errAdd := addFiles(target, files, 0)
+	if errAdd != nil {
+		return errAdd
+	}
+	if skipped > 0 {
+		log.ErrorReport("playbackTo()", strconv.Itoa(skipped)+" songs are not on "+server.Name+" and were skipped.")
+	}
+
+	if status["state"] == "play" || status["state"] == "pause" {
+		song, errSong := strconv.Atoi(status["song"])
+		elapsed, errElapsed := strconv.ParseFloat(status["elapsed"], 64)
+		if errSong != nil || errElapsed != nil || song < 0 || song >= len(ids) {
+			return fmt.Errorf("could not establish the current position")
+		}
+		// The songs skipped before it have moved the current song up, so
+		// it is found by the ID it got rather than its position.
+		if ids[song] == TRANSFER_SKIPPED {
+			return fmt.Errorf("the current song is not on %s", server.Name)
+		}
+		// Seeking starts playback as well.
+		if errSeek := target.SeekId(ids[song], int(elapsed)); errSeek != nil {
+			return errSeek
+		}
+		if status["state"] == "pause" {
+			if errPause := target.Pause(true); errPause != nil {
+				return errPause
+			}
+		}
+	}
+
+	return nil
+
+} // end playbackTo
+
+// queueMirror copies every change of the current playlist to other server
+// profiles. Their playlists are brought in line by keeping what already
+// matches at the start and replacing the rest, so that appending to the
+// source (the usual edit) does not disturb what the others are playing.
+type queueMirror struct {
+	targets map[string]bool
+	version string         // playlist version last mirrored
+	jobs    chan mirrorJob // latest playlist to be mirrored, by mirrorLoop()
+}
+
+// mirrorJob is a playlist to be mirrored to some profiles. The profiles
+// are copied, as the config is only for update() to touch.
+type mirrorJob struct {
+	targets []config.Server
+	files   []string
+}
+
+// toggle starts or stops mirroring to the named profile.
+func (mirror *queueMirror) toggle(name string, enable bool) {
+
+	if mirror.targets == nil {
+		mirror.targets = make(map[string]bool)
+	}
+	if enable {
+		mirror.targets[name] = true
+	} else {
+		delete(mirror.targets, name)
+	}
+	// Force the next sync, so that a new target catches up at once.
+	mirror.forget()
+
+} // end toggle
+
+// forget forgets the playlist last mirrored, so that the next sync mirrors
+// whatever the playlist is then (of another server, after a switch).
+func (mirror *queueMirror) forget() {
+
+	mirror.version = ""
+
+} // end forget
+
+// sync mirrors the current playlist if it has changed since the last time.
+// The mirrors are brought in line on a goroutine of their own, so that a
+// slow or unreachable one does not hold update() (and the UI) up.
+func (mirror *queueMirror) sync(mpdConnection *mpd.Client, status mpd.Attrs) {
+
+	if len(mirror.targets) == 0 || status["playlist"] == mirror.version {
+		return
+	}
+	mirror.version = status["playlist"]
+
+	files, errFiles := queueFiles(mpdConnection)
+	if errFiles != nil {
+		log.ErrorReport("queueMirror.sync()", "Could not list the current playlist ("+errFiles.Error()+").")
+		return
+	}
+
+	job := mirrorJob{files: files}
+	for name := range mirror.targets {
+		// The server in use is never its own mirror.
+		if name == config.Get().CurrentServer().Name {
+			continue
+		}
+		if server := findServer(name); server == nil {
+			log.ErrorReport("queueMirror.sync()", "There is no server named "+name+" to mirror to.")
+		} else {
+			job.targets = append(job.targets, *server)
+		}
+	}
+
+	if mirror.jobs == nil {
+		mirror.jobs = make(chan mirrorJob, 1)
+		go mirrorLoop(mirror.jobs)
+	}
+	// A playlist still waiting to be mirrored is out of date, so it is
+	// replaced (only update() sends, so this never blocks).
+	select {
+	case <-mirror.jobs:
+	default:
+	}
+	mirror.jobs <- job
+
+} // end sync
+
+// mirrorLoop mirrors every playlist it is given, one after the other.
+func mirrorLoop(jobs <-chan mirrorJob) {
+
+	for job := range jobs {
+		for i := range job.targets {
+			if errMirror := mirrorTo(&job.targets[i], job.files); errMirror != nil {
+				log.ErrorReport("mirrorLoop()", "Could not mirror the current playlist to "+job.targets[i].Name+" ("+errMirror.Error()+").")
+			}
+		}
+	}
+
+} // end mirrorLoop
+
+// mirrorTo makes the current playlist of a profile match files.
+func mirrorTo(server *config.Server, files []string) error {
+
+	target, errDial := dialServerTimeout(server, TRANSFER_DIAL_TIMEOUT)
+	if errDial != nil {
+		return errDial
+	}
+	defer target.Close()
+
+	current, errCurrent := queueFiles(target)
+	if errCurrent != nil {
+		return errCurrent
+	}
+
+	same := 0
+	for same < len(files) && same < len(current) && files[same] == current[same] {
+		same++
+	}
+	if same < len(current) {
+		if errDelete := target.Delete(same, len(current)); errDelete != nil {
+			return errDelete
+		}
+	}
+	_, skipped, errAdd := addFiles(target, files[same:], same)
+	if errAdd != nil {
+		return errAdd
+	}
+	if skipped > 0 {
+		log.ErrorReport("mirrorTo()", strconv.Itoa(skipped)+" songs are not on "+server.Name+" and were skipped.")
+	}
+	return nil
+
+} // end mirrorTo
@@ -0,0 +1,172 @@
+/*
+This file is part of Juke MPD client. See juke.go for more details.
+
+This particular file has the undo and redo of edits to the current playlist.
+*/
+
+package main
+
+import (
+	"github.com/fhs/gompd/mpd"
+	"github.com/idealeric/juke/log"
+	"github.com/idealeric/juke/ui"
+	"strconv"
+)
+
+// How many edits can be undone.
+const UNDO_DEPTH = 20
+
+// queueSnapshot is the current playlist at one point in time.
+type queueSnapshot struct {
+	files      []string // songs, in order
+	priorities []int    // priority of each song
+	song       int      // position of the current song (-1 if none)
+	elapsed    float64  // seconds into the current song
+	state      string   // play, pause or stop
+}
+
+// queueHistory holds the snapshots taken before each edit (to undo) and the
+// ones taken before each undo (to redo).
+type queueHistory struct {
+	undos []*queueSnapshot
+	redos []*queueSnapshot
+}
+
+// takeSnapshot records the current playlist.
+func takeSnapshot(mpdConnection *mpd.Client) (*queueSnapshot, error) {
+
+	status, errStatus := mpdConnection.Status()
+	if errStatus != nil {
+		return nil, errStatus
+	}
+	queue, errQueue := mpdConnection.PlaylistInfo(-1, -1)
+	if errQueue != nil {
+		return nil, errQueue
+	}
+
+	snapshot := &queueSnapshot{
+		files:      make([]string, len(queue)),
+		priorities: make([]int, len(queue)),
+		song:       -1,
+		state:      status["state"]}
+	for i, song := range queue {
+		snapshot.files[i] = song["file"]
+		snapshot.priorities[i], _ = strconv.Atoi(song["Prio"])
+	}
+	if song, errSong := strconv.Atoi(status["song"]); errSong == nil {
+		snapshot.song = song
+		snapshot.elapsed, _ = strconv.ParseFloat(status["elapsed"], 64)
+	}
+	return snapshot, nil
+
+} // end takeSnapshot
+
+// replace replaces the current playlist with the songs of a snapshot and
+// their priorities, as a single command list. The MPD library's command
+// lists have no prio, so the list is sent as one command of many lines.
+func (snapshot *queueSnapshot) replace(mpdConnection *mpd.Client) error {
+
+	format := "command_list_begin\nclear"
+	args := make([]interface{}, 0, len(snapshot.files))
+	for _, file := range snapshot.files {
+		format += "\nadd %s"
+		args = append(args, file)
+	}
+	for pos, priority := range snapshot.priorities {
+		if priority > 0 {
+			format += "\nprio %d %d"
+			args = append(args, priority, pos)
+		}
+	}
+	format += "\ncommand_list_end"
+	return mpdConnection.Command(format, args...).OK()
+
+} // end replace
+
+// resume returns to the snapshot's song at the same position (and in the
+// same state), once the playlist is replaced. Seeking starts playback, so
+// a stopped snapshot is left stopped without a current song rather than
+// played for a moment. A paused one is paused in the same command list.
+func (snapshot *queueSnapshot) resume(mpdConnection *mpd.Client) error {
+
+	if snapshot.state == "stop" || snapshot.song < 0 || snapshot.song >= len(snapshot.files) {
+		return nil
+	}
+	cmdList := mpdConnection.BeginCommandList()
+	cmdList.Seek(snapshot.song, int(snapshot.elapsed))
+	if snapshot.state == "pause" {
+		cmdList.Pause(true)
+	}
+	return cmdList.End()
+
+} // end resume
+
+// record takes a snapshot before an edit. Anything undone so far can no
+// longer be redone.
+func (history *queueHistory) record(mpdConnection *mpd.Client) {
+
+	snapshot, errSnapshot := takeSnapshot(mpdConnection)
+	if errSnapshot != nil {
+		log.ErrorReport("queueHistory.record()", "Could not take a snapshot of the current playlist ("+errSnapshot.Error()+").")
+		return
+	}
+	history.undos = pushSnapshot(history.undos, snapshot)
+	history.redos = nil
+	history.show()
+
+} // end record
+
+// step undoes (or redoes) the last edit (or undo), keeping a snapshot of
+// the playlist replaced to go back to.
+func (history *queueHistory) step(mpdConnection *mpd.Client, redo bool) error {
+
+	from, to := &history.undos, &history.redos
+	if redo {
+		from, to = to, from
+	}
+	if len(*from) == 0 {
+		return nil
+	}
+
+	current, errSnapshot := takeSnapshot(mpdConnection)
+	if errSnapshot != nil {
+		return errSnapshot
+	}
+	snapshot := (*from)[len(*from)-1]
+	if errReplace := snapshot.replace(mpdConnection); errReplace != nil {
+		return errReplace
+	}
+	// The playlist is replaced, whether or not its song can be resumed.
+	*from = (*from)[:len(*from)-1]
+	*to = pushSnapshot(*to, current)
+	history.show()
+	return snapshot.resume(mpdConnection)
+
+} // end step
+
+// forget drops every snapshot, they belong to another playlist.
+func (history *queueHistory) forget() {
+
+	history.undos, history.redos = nil, nil
+	history.show()
+
+} // end forget
+
+// show tells the UI what can be undone and redone.
+func (history *queueHistory) show() {
+
+	ui.SetUndoAvailable(len(history.undos) > 0, len(history.redos) > 0)
+
+} // end show
+
+// pushSnapshot adds a snapshot to a stack, dropping the oldest beyond
+// UNDO_DEPTH.
+func pushSnapshot(stack []*queueSnapshot, snapshot *queueSnapshot) []*queueSnapshot {
+
+	stack = append(stack, snapshot)
+	if len(stack) > UNDO_DEPTH {
+		stack = stack[len(stack)-UNDO_DEPTH:]
+	}
+	return stack
+
+} // end pushSnapshot
@@ -12,6 +12,7 @@ import (
 	"github.com/mattn/go-gtk/glib"
 	"github.com/mattn/go-gtk/gtk"
 	"strconv"
+	"time"
 	"unsafe"
 )
 
@@ -33,15 +34,17 @@ const (
 const (
 	VOLUME_BUTTON uint8 = iota
 	CONNECTION_BUTTON
+	MENU_BUTTON
 )
 
 // Constant referances for set program states:
 const (
-	NOT_CONNECTED_WINDOW_TITLE string = "Not Connected [Juke]"
+	NOT_CONNECTED_WINDOW_TITLE string = "Not Connected"
 	NOT_CONNECTED_SONG_LABEL   string = "<span size=\"x-large\" font_weight=\"bold\">Stopped</span>\nNot connected."
-	STOPPED_WINDOW_TITLE       string = "Stopped [Juke]"
+	STOPPED_WINDOW_TITLE       string = "Stopped"
 	STOPPED_SONG_LABEL         string = "<span size=\"x-large\" font_weight=\"bold\">Stopped</span>\nConnected."
 	STOPPED_OR_DC_PROGRESS     string = "0:00 / 0:00"
+	STREAM_TOOLTIP             string = "A stream can not be seeked"
 )
 
 // Constant pixmap paths:
@@ -49,26 +52,120 @@ const (
 	ICON              string = "/usr/share/pixmaps/juke/juke.png"
 	NO_COVER_ARTWORK  string = "/usr/share/pixmaps/juke/no_cover.png"
 	CUR_PL_ALBUM_SIZE int    = 20
+	CUR_PL_MAX_RATING int    = 5
 )
 
 const (
 	CUR_PL_COL_ID int = iota
 	CUR_PL_COL_ARTPATH
 	CUR_PL_COL_ARTBUF
+	CUR_PL_COL_FILE
+	CUR_PL_COL_SECONDS
+	CUR_PL_COL_ARTSHOWN
+	CUR_PL_COL_SEARCH // plain title and artist, for the playlist's search
 	CUR_PL_COL_NAME
 	CUR_PL_COL_ARTIST
 	CUR_PL_COL_ALBUM
+	CUR_PL_COL_RATING
+	CUR_PL_COL_PRIORITY
+	CUR_PL_COL_TRACK
+	CUR_PL_COL_DISC
+	CUR_PL_COL_DURATION
+	CUR_PL_COL_GENRE
+	CUR_PL_COL_DATE
+	CUR_PL_COL_COMPOSER
+	CUR_PL_COL_PERFORMER
+	CUR_PL_COL_ALBUM_ARTIST
+	CUR_PL_COL_PATH
+	CUR_PL_COL_POSITION
+	CUR_PL_COL_BITRATE
 	NUM_PL_COLS
 )
 
+// Queue operations on the selected rows of the current playlist (in the
+// order of the popup menu):
+type QueueOperation uint8
+
+const (
+	QUEUE_PLAY_NEXT QueueOperation = iota
+	QUEUE_MOVE_TOP
+	QUEUE_MOVE_BOTTOM
+	QUEUE_CROP
+	QUEUE_SHUFFLE
+	QUEUE_PRIORITY
+)
+
+// PriorityLevel is a queue priority offered in the popup menu.
+type PriorityLevel struct {
+	Label    string
+	Priority int
+}
+
+// The priorities offered, MPD's go from 0 (none) to 255.
+var PRIORITY_LEVELS = []PriorityLevel{
+	{"Highest", 255},
+	{"High", 192},
+	{"Medium", 128},
+	{"Low", 64},
+	{"None", 0}}
+
+// Tags the current playlist can be sorted by:
+type SortKey uint8
+
+const (
+	SORT_TITLE SortKey = iota
+	SORT_ARTIST
+	SORT_ALBUM_ARTIST
+	SORT_ALBUM
+	SORT_DATE
+	SORT_DISC
+	SORT_TRACK
+	SORT_RATING
+	SORT_PRIORITY
+	SORT_GENRE
+	SORT_COMPOSER
+	SORT_PERFORMER
+	SORT_FILE
+	SORT_DURATION
+)
+
+// The keys a click on each column sorts by, most significant first. Songs
+// equal on every key keep their order. Columns not listed do not sort.
+var columnSortKeys = map[int][]SortKey{
+	CUR_PL_COL_NAME:         {SORT_TITLE},
+	CUR_PL_COL_ARTIST:       {SORT_ARTIST, SORT_DATE, SORT_ALBUM, SORT_DISC, SORT_TRACK},
+	CUR_PL_COL_ALBUM:        {SORT_ALBUM_ARTIST, SORT_DATE, SORT_ALBUM, SORT_DISC, SORT_TRACK},
+	CUR_PL_COL_RATING:       {SORT_RATING},
+	CUR_PL_COL_PRIORITY:     {SORT_PRIORITY},
+	CUR_PL_COL_TRACK:        {SORT_TRACK},
+	CUR_PL_COL_DISC:         {SORT_DISC, SORT_TRACK},
+	CUR_PL_COL_DURATION:     {SORT_DURATION},
+	CUR_PL_COL_GENRE:        {SORT_GENRE, SORT_ALBUM_ARTIST, SORT_DATE, SORT_ALBUM, SORT_DISC, SORT_TRACK},
+	CUR_PL_COL_DATE:         {SORT_DATE, SORT_ALBUM, SORT_DISC, SORT_TRACK},
+	CUR_PL_COL_COMPOSER:     {SORT_COMPOSER},
+	CUR_PL_COL_PERFORMER:    {SORT_PERFORMER},
+	CUR_PL_COL_ALBUM_ARTIST: {SORT_ALBUM_ARTIST, SORT_DATE, SORT_ALBUM, SORT_DISC, SORT_TRACK},
+	CUR_PL_COL_PATH:         {SORT_FILE}}
+
 // CurrentPLRow is an abstraction for other modules to
 // work with visible rows in the current playlist.
 type CurrentPLRow struct {
 	ID          int
 	ArtworkPath string
+	File        string
 	Name        string
 	Artist      string
 	Album       string
+	Rating      int
+	Priority    int
+	Track       string
+	Disc        string
+	Duration    int // seconds, -1 if unknown
+	Genre       string
+	Date        string
+	Composer    string
+	Performer   string
+	AlbumArtist string
 	Bold        bool
 	gref        *gtk.TreeRowReference
 }
@@ -83,7 +180,7 @@ var (
 	window              *gtk.Window                  // Main window
 	leftControls        [2]*gtk.Button               // The 2 shuffle/repeat buttons
 	playBackControls    [4]*gtk.Button               // The 4 playback buttons
-	rightControls       [2]*gtk.Button               // The 2 connection/volume buttons
+	rightControls       [3]*gtk.Button               // The 3 connection/volume/menu buttons
 	controlsSize        int                          // The height of the controls (for current albumart resizing)
 	currentAlbumArt     *gtk.Image                   // The current song's album artwork
 	currentAlbumArtPath string                       // The current song's album artwork
@@ -93,20 +190,59 @@ var (
 	progressBarEvent    *gtk.EventBox                // Progress bar eventbox (for click events)
 	playlistTree        *gtk.TreeView                // Treeview for the current playlist.
 	playlistModel       *gtk.ListStore               // Model for the current playlist.
-	playlistSortable    *gtk.TreeSortable            // Playlist sortable.
+	playlistSortColumn  int                          // Column the current playlist was last sorted by (-1 if none).
+	playlistSortDown    bool                         // Whether that sort was descending.
 	playlistSelection   *gtk.TreeSelection           // Treeview selection for the current playlist.
 	playlistMenuRemove  *gtk.MenuItem                // Treeview popup menu item for remove.
 	playlistMenuClear   *gtk.MenuItem                // Treeview popup menu item for clear.
-	playlistCols        [3]*gtk.TreeViewColumn       // Playlist columns.
+	playlistMenuQueue   []*gtk.MenuItem              // Treeview popup menu items for queue operations (by QueueOperation).
+	playlistMenuPrios   []*gtk.MenuItem              // Treeview popup menu items for priorities (by PRIORITY_LEVELS).
+	playlistMenuUndo    *gtk.MenuItem                // Treeview popup menu item for undo.
+	playlistMenuRedo    *gtk.MenuItem                // Treeview popup menu item for redo.
+	playlistCols        []*gtk.TreeViewColumn        // Playlist columns (from CUR_PL_COL_NAME on).
+	mainMenu            *gtk.Menu                    // Menu for everything that is not a button.
+	serverMenu          *gtk.Menu                    // Menu of the server profiles (popped up by the connection button).
+	serverMenuReconnect *gtk.MenuItem                // Server menu item to reconnect to the profile in use.
+	serverMenuItems     []*gtk.CheckMenuItem         // Server menu items, one per profile.
+	serverMenuSends     []*gtk.MenuItem              // Server menu items to send playback, one per profile.
+	serverMenuMirrors   []*gtk.CheckMenuItem         // Server menu items to mirror the queue, one per profile.
+	serverMenuStop      *gtk.CheckMenuItem           // Server menu item to stop here after sending playback.
+	currentServer       int                          // Index of the profile in use.
+	mainMenuStatistics  *gtk.MenuItem                // Main menu item for the listening statistics.
+	mainMenuRockbox     *gtk.MenuItem                // Main menu item for the Rockbox scrobble export.
+	mainMenuLBrainz     *gtk.MenuItem                // Main menu item for the ListenBrainz scrobble export.
+	mainMenuAutoDJ      *gtk.CheckMenuItem           // Main menu item for the auto-DJ.
+	mainMenuParty       *gtk.CheckMenuItem           // Main menu item for party mode.
+	mainMenuRequests    *gtk.MenuItem                // Main menu item for the party requests window.
+	smartMenu           *gtk.Menu                    // Submenu of the smart playlists.
+	smartMenuAdds       []*gtk.MenuItem              // Smart playlist items for adding to the current playlist.
+	smartMenuSaves      []*gtk.MenuItem              // Smart playlist items for saving as a stored playlist.
+	smartNames          []string                     // Names of the smart playlists.
+	radioMenu           *gtk.Menu                    // Submenu of the radio bookmarks.
+	radioMenuItems      []*gtk.MenuItem              // Radio bookmark items for adding the station to the current playlist.
+	radioNames          []string                     // Names of the radio bookmarks.
+	serverNames         []string                     // Names of the server profiles.
 	currentBoldRow      CurrentPLRow                 // Currently bolded row reference.
 	currentArtworks     map[string]*curArtWrkStorage // Hash table for fast artwork lookup.
 )
 
+// SetUndoAvailable enables the undo and redo items of the current playlist
+// menu (and their shortcuts) when there is something to undo or redo.
+func SetUndoAvailable(undo, redo bool) {
+
+	undoAvailable, redoAvailable = undo, redo
+	playlistMenuUndo.SetSensitive(undo)
+	playlistMenuRedo.SetSensitive(redo)
+
+} // end SetUndoAvailable
+
 // MainLoop runs the GUI toolkit's main loop.
 func MainLoop() {
 
 	gdk.ThreadsEnter()
+	go tickProgress()
 	gtk.Main()
+	progressEnded = true
 	gdk.ThreadsLeave()
 
 } // end MainLoop
@@ -132,8 +268,7 @@ func SetCurrentSong(songName, artist, album string) {
 		songLabel += escapeHTML(artist)
 	}
 
-	windowTitle += " [Juke]"
-	window.SetTitle(windowTitle)
+	setWindowTitle(windowTitle)
 
 	if album != "" {
 		songLabel += " from " + escapeHTML(album)
@@ -143,11 +278,33 @@ func SetCurrentSong(songName, artist, album string) {
 
 } // end SetCurrentSong
 
+// SetCurrentStream changes the window title and current song labeling to
+// reflect a stream: the name of the station and the title it currently
+// plays (either may be empty).
+func SetCurrentStream(station, title string) {
+
+	windowTitle, songLabel := station, "<span size=\"x-large\" font_weight=\"bold\">"
+	if title == "" {
+		songLabel += escapeHTML(station) + "</span>\nStream"
+	} else {
+		if station != "" {
+			windowTitle = title + " on " + station
+		} else {
+			windowTitle = title
+		}
+		songLabel += escapeHTML(title) + "</span>\non " + escapeHTML(station)
+	}
+
+	setWindowTitle(windowTitle)
+	currentSongTitle.SetMarkup(songLabel)
+
+} // end SetCurrentStream
+
 // SetCurrentSongNotConnected changes the window title and current song labeling
 // to reflect and unconnected client.
 func SetCurrentSongNotConnected() {
 
-	window.SetTitle(NOT_CONNECTED_WINDOW_TITLE)
+	setWindowTitle(NOT_CONNECTED_WINDOW_TITLE)
 	currentSongTitle.SetMarkup(NOT_CONNECTED_SONG_LABEL)
 
 } // end SetCurrentSongNotConnected
@@ -156,7 +313,7 @@ func SetCurrentSongNotConnected() {
 // reflect a stopped but still connected client.
 func SetCurrentSongStopped() {
 
-	window.SetTitle(STOPPED_WINDOW_TITLE)
+	setWindowTitle(STOPPED_WINDOW_TITLE)
 	currentSongTitle.SetMarkup(STOPPED_SONG_LABEL)
 
 } // end SetCurrentSongStopped
@@ -175,7 +332,7 @@ func InitInterface() {
 	window.SetPosition(gtk.WIN_POS_CENTER)
 	window.SetIconFromFile(ICON)
 	window.SetSizeRequest(800, -1) // TODO - Remember size
-	window.SetTitle(NOT_CONNECTED_WINDOW_TITLE)
+	setWindowTitle(NOT_CONNECTED_WINDOW_TITLE)
 	window.SetBorderWidth(8)
 
 	// Ensure we can do icons on buttons.
@@ -190,37 +347,47 @@ func InitInterface() {
 	// Current playlist treeview:
 	currentArtworks = make(map[string]*curArtWrkStorage)
 	playlistTree = gtk.NewTreeView()
-	playlistModel = gtk.NewListStore(gtk.TYPE_INT, gtk.TYPE_STRING, gdkpixbuf.GetType(), gtk.TYPE_STRING, gtk.TYPE_STRING, gtk.TYPE_STRING)
-	playlistSortable = gtk.NewTreeSortable(playlistModel)
+	playlistTypes := []interface{}{gtk.TYPE_INT, gtk.TYPE_STRING, gdkpixbuf.GetType(), gtk.TYPE_STRING, gtk.TYPE_INT, gtk.TYPE_BOOL, gtk.TYPE_STRING}
+	for ci := CUR_PL_COL_NAME; ci < NUM_PL_COLS; ci++ {
+		playlistTypes = append(playlistTypes, gtk.TYPE_STRING)
+	}
+	playlistModel = gtk.NewListStore(playlistTypes...)
 	//playlistTree.SetReorderable(true) // TODO - reordering
 	playlistTree.SetModel(playlistModel)
-	playlistColNames := []string{"ID", "ArtPath", "ArtBuf", "Name", "Artist", "Album"}
+	// The text columns hold markup, so the search has a column of its own.
+	playlistTree.SetSearchColumn(CUR_PL_COL_SEARCH)
+	playlistCols = make([]*gtk.TreeViewColumn, NUM_PL_COLS-CUR_PL_COL_NAME)
 	var playlistCol *gtk.TreeViewColumn
 	for ci := CUR_PL_COL_NAME; ci < NUM_PL_COLS; ci++ {
+		column := playlistColumns[ci-CUR_PL_COL_NAME]
 		if ci == CUR_PL_COL_NAME {
 			playlistCol = gtk.NewTreeViewColumn()
 			playlistCol.SetSpacing(3)
-			playlistCol.SetTitle(playlistColNames[CUR_PL_COL_NAME])
-			playlistCol.SetMinWidth(370) // TODO - Remember sizing.
+			playlistCol.SetTitle(column.title)
 			cellPix := gtk.NewCellRendererPixbuf()
 			playlistCol.PackStart(cellPix, false)
 			playlistCol.AddAttribute(cellPix, "pixbuf", CUR_PL_COL_ARTBUF)
+			playlistCol.AddAttribute(cellPix, "visible", CUR_PL_COL_ARTSHOWN)
 			cellText := gtk.NewCellRendererText()
 			playlistCol.PackStart(cellText, true)
 			playlistCol.AddAttribute(cellText, "markup", CUR_PL_COL_NAME)
-			playlistCol.SetSortColumnId(CUR_PL_COL_NAME)
-			playlistSortable.SetSortFunc(CUR_PL_COL_NAME, makeSortFunc(CUR_PL_COL_NAME))
 		} else {
-			playlistCol = gtk.NewTreeViewColumnWithAttributes(playlistColNames[ci], gtk.NewCellRendererText(), "markup", ci)
-			playlistCol.SetMinWidth(190)
-			playlistCol.SetSortColumnId(ci)
-			playlistSortable.SetSortFunc(ci, makeSortFunc(ci))
+			playlistCol = gtk.NewTreeViewColumnWithAttributes(column.title, gtk.NewCellRendererText(), "markup", ci)
 		}
+		// Sorting is done by the server, see CurrentColumnClick.
+		_, sorts := columnSortKeys[ci]
+		playlistCol.SetClickable(sorts)
 		playlistCol.SetResizable(true)
-		playlistCol.SetSizing(gtk.TREE_VIEW_COLUMN_FIXED) // TODO - Remember sizing.
+		playlistCol.SetReorderable(true)
+		playlistCol.SetSizing(gtk.TREE_VIEW_COLUMN_FIXED)
+		playlistCol.SetMinWidth(COLUMN_MIN_WIDTH)
+		playlistCol.SetFixedWidth(column.width)
+		// Shown as the layout says, see SetColumnLayout.
+		playlistCol.SetVisible(false)
 		playlistTree.AppendColumn(playlistCol)
 		playlistCols[ci-CUR_PL_COL_NAME] = playlistCol
 	}
+	playlistSortColumn = -1
 	playlistScroll := gtk.NewScrolledWindow(nil, nil)
 	playlistScroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_ALWAYS)
 	playlistScroll.Add(playlistTree)
@@ -230,12 +397,37 @@ func InitInterface() {
 	// Current playlist right click menu:
 	playlistSelection = playlistTree.GetSelection()
 	playlistSelection.SetMode(gtk.SELECTION_MULTIPLE)
+	initSummary(mainBox)
 	playlistMenu := gtk.NewMenu()
 	playlistMenuRemove = gtk.NewMenuItemWithLabel("Remove Song(s)")
 	playlistMenu.Append(playlistMenuRemove)
 	playlistMenu.Append(gtk.NewSeparatorMenuItem())
+	for _, label := range []string{"Play Next", "Move to Top", "Move to Bottom", "Crop to Selection", "Shuffle Selection"} {
+		item := gtk.NewMenuItemWithLabel(label)
+		playlistMenu.Append(item)
+		playlistMenuQueue = append(playlistMenuQueue, item)
+	}
+	playlistMenuPriority := gtk.NewMenuItemWithLabel("Set Priority")
+	priorityMenu := gtk.NewMenu()
+	for _, level := range PRIORITY_LEVELS {
+		item := gtk.NewMenuItemWithLabel(level.Label)
+		priorityMenu.Append(item)
+		playlistMenuPrios = append(playlistMenuPrios, item)
+	}
+	playlistMenuPriority.SetSubmenu(priorityMenu)
+	playlistMenu.Append(playlistMenuPriority)
+	playlistMenu.Append(gtk.NewSeparatorMenuItem())
 	playlistMenuClear = gtk.NewMenuItemWithLabel("Clear Playlist")
 	playlistMenu.Append(playlistMenuClear)
+	playlistMenu.Append(gtk.NewSeparatorMenuItem())
+	playlistMenuUndo = gtk.NewMenuItemWithLabel("Undo")
+	playlistMenuUndo.SetSensitive(false)
+	playlistMenu.Append(playlistMenuUndo)
+	playlistMenuRedo = gtk.NewMenuItemWithLabel("Redo")
+	playlistMenuRedo.SetSensitive(false)
+	playlistMenu.Append(playlistMenuRedo)
+	playlistMenu.Append(gtk.NewSeparatorMenuItem())
+	initColumnsMenu(playlistMenu)
 	playlistMenu.ShowAll()
 	playlistTree.Connect("button-press-event", func(cntx *glib.CallbackContext) bool {
 		arg := cntx.Args(0)
@@ -274,6 +466,7 @@ func InitInterface() {
 	progressBar.SetPulseStep(0.05)
 	//progressBar.SetEllipsize(0.05) // TODO - Implement this (maybe)
 	progressBarEvent.Add(progressBar)
+	initProgressBar()
 	progressAndControls.PackStart(progressBarEvent, false, false, 0)
 
 	// Current song labeling:
@@ -284,6 +477,13 @@ func InitInterface() {
 	currentSongTitleAlign.Add(currentSongTitle)
 	progressAndControls.PackStart(currentSongTitleAlign, false, false, 0)
 
+	// Multi-room group state (only shown with a group):
+	roomStatesAlign := gtk.NewAlignment(0, 0, 0, 1)
+	roomStatesLabel = gtk.NewLabel("")
+	roomStatesLabel.SetNoShowAll(true)
+	roomStatesAlign.Add(roomStatesLabel)
+	progressAndControls.PackStart(roomStatesAlign, false, false, 0)
+
 	// Left-hand controls:
 	leftControlsBox := gtk.NewHBox(false, 0)
 
@@ -336,12 +536,70 @@ func InitInterface() {
 	rightControls[VOLUME_BUTTON] = gtk.NewButtonFromStock(gtk.STOCK_SAVE)
 	rightControls[VOLUME_BUTTON].SetImage(gtk.NewImageFromStock(gtk.STOCK_SAVE, gtk.ICON_SIZE_DND))
 
+	rightControls[MENU_BUTTON] = gtk.NewButtonFromStock(gtk.STOCK_PREFERENCES)
+	rightControls[MENU_BUTTON].SetImage(gtk.NewImageFromStock(gtk.STOCK_PREFERENCES, gtk.ICON_SIZE_DND))
+
 	for i := range rightControls {
 		rightControls[i].SetCanFocus(false)
 		rightControls[i].SetRelief(gtk.RELIEF_HALF)
 		rightControls[i].SetLabel("")
 		rightControlsBox.PackStart(rightControls[i], false, false, 0)
 	}
+	// Main menu (popped up by the menu button):
+	mainMenu = gtk.NewMenu()
+	mainMenuStatistics = gtk.NewMenuItemWithLabel("Listening Statistics...")
+	mainMenu.Append(mainMenuStatistics)
+	mainMenuSmart := gtk.NewMenuItemWithLabel("Smart Playlists")
+	smartMenu = gtk.NewMenu()
+	mainMenuSmart.SetSubmenu(smartMenu)
+	mainMenu.Append(mainMenuSmart)
+	mainMenuRadio := gtk.NewMenuItemWithLabel("Radio")
+	radioMenu = gtk.NewMenu()
+	mainMenuRadio.SetSubmenu(radioMenu)
+	mainMenu.Append(mainMenuRadio)
+	mainMenuAutoDJ = gtk.NewCheckMenuItemWithLabel("Auto-DJ")
+	mainMenu.Append(mainMenuAutoDJ)
+	mainMenuParty = gtk.NewCheckMenuItemWithLabel("Party Mode")
+	mainMenu.Append(mainMenuParty)
+	mainMenuRequests = gtk.NewMenuItemWithLabel("Party Requests...")
+	mainMenu.Append(mainMenuRequests)
+	mainMenuRequests.Connect("activate", ShowPartyWindow)
+	registerCommand("party_requests", "Show the party requests", func() bool {
+		ShowPartyWindow()
+		return true
+	})
+	mainMenuOutputs = gtk.NewMenuItemWithLabel("Audio Outputs...")
+	mainMenu.Append(mainMenuOutputs)
+	mainMenuSettings = gtk.NewMenuItemWithLabel("Playback Settings...")
+	mainMenu.Append(mainMenuSettings)
+	mainMenuAlbumView = gtk.NewCheckMenuItemWithLabel("Group by Album")
+	mainMenu.Append(mainMenuAlbumView)
+	mainMenuShortcuts = gtk.NewMenuItemWithLabel("Keyboard Shortcuts...")
+	mainMenu.Append(mainMenuShortcuts)
+	mainMenuPalette = gtk.NewMenuItemWithLabel("Command Palette...")
+	mainMenu.Append(mainMenuPalette)
+	mainMenu.Append(gtk.NewSeparatorMenuItem())
+	mainMenuRockbox = gtk.NewMenuItemWithLabel("Export Scrobbles (Rockbox)...")
+	mainMenu.Append(mainMenuRockbox)
+	mainMenuLBrainz = gtk.NewMenuItemWithLabel("Export Scrobbles (ListenBrainz)...")
+	mainMenu.Append(mainMenuLBrainz)
+	mainMenu.ShowAll()
+	rightControls[MENU_BUTTON].Connect("released", func() {
+		mainMenu.Popup(nil, nil, nil, nil, 0, 0)
+	})
+
+	// Server menu (popped up by the connection button, filled by SetServers):
+	serverMenu = gtk.NewMenu()
+	serverMenuReconnect = gtk.NewMenuItemWithLabel("Reconnect")
+	serverMenu.Append(serverMenuReconnect)
+	serverMenu.Append(gtk.NewSeparatorMenuItem())
+	serverMenuPartition = gtk.NewMenuItemWithLabel("Partitions...")
+	serverMenu.Append(serverMenuPartition)
+	serverMenu.Append(gtk.NewSeparatorMenuItem())
+	rightControls[CONNECTION_BUTTON].Connect("released", func() {
+		serverMenu.Popup(nil, nil, nil, nil, 0, 0)
+	})
+
 	rightControlsAlign := gtk.NewAlignment(1, 0, 0, 1)
 	rightControlsAlign.Add(rightControlsBox)
 	controls.PackStart(rightControlsAlign, true, true, 0)
@@ -360,6 +618,16 @@ func InitInterface() {
 	SetCurrentAlbumArt(NO_COVER_ARTWORK)
 	currentAlbumArt.Show()
 
+	// Secondary windows are built up front, but only shown on demand.
+	initStatisticsWindow()
+	initPartyWindow()
+	initVolumeWindow()
+	initOutputsWindow()
+	initPartitionsWindow()
+	initSettingsWindow()
+	initShortcutsWindow()
+	initPaletteWindow()
+
 } // end Init
 
 // SetCurrentAlbumArt sets the current album artwork to the image specified by path.
@@ -397,25 +665,21 @@ func SetPlayPause(pause bool) {
 		playBackControls[PLAY_PAUSE_BUTTON].SetImage(gtk.NewImageFromStock(gtk.STOCK_MEDIA_PLAY, gtk.ICON_SIZE_DND))
 		currentPause = false
 	}
+	runProgress(currentPause)
 
 } // end SetPlayPause
 
-// SetProgressBarTime takes song progress and updates the progress bar to
-// reflect that both textually and visually.
-func SetProgressBarTime(at, total int) {
+// SetProgressBarTime takes song progress (in seconds) and updates the
+// progress bar to reflect that both textually and visually. While the song
+// plays, the progress bar goes on from there on its own. A total of 0 is a
+// stream (or a song of unknown length), of which only the time elapsed is
+// shown.
+func SetProgressBarTime(at, total float64) {
 
-	atSeconds, totalSeconds := at%60, total%60
-	timeText := strconv.Itoa(at/60) + ":"
-	if atSeconds < 10 {
-		timeText += "0"
-	}
-	timeText += strconv.Itoa(atSeconds) + " / " + strconv.Itoa(total/60) + ":"
-	if totalSeconds < 10 {
-		timeText += "0"
-	}
-	timeText += strconv.Itoa(totalSeconds)
-	progressBar.SetText(timeText)
-	progressBar.SetFraction(float64(at) / float64(total))
+	progressElapsed, progressDuration = at, total
+	progressLive = total <= 0
+	progressAnchor = time.Now()
+	drawProgress()
 
 } // end SetProgressBarTime
 
@@ -423,8 +687,11 @@ func SetProgressBarTime(at, total int) {
 // the client is stopped or is disconnected.
 func SetProgressBarTimeStoppedOrDisconnected() {
 
+	progressElapsed, progressDuration = 0, 0
+	progressLive = false
 	progressBar.SetText(STOPPED_OR_DC_PROGRESS)
 	progressBar.SetFraction(0.0)
+	setElapsed(0)
 
 } // end SetProgressBarTimeStoppedOrDisconnected
 
@@ -451,6 +718,9 @@ func RemoveManyRowsfromCurrentPlaylist(rowsList *list.List) {
 	for e := rowsList.Front(); e != nil; e = e.Next() {
 		RemoveRowfromCurrentPlaylist(e.Value.(*CurrentPLRow))
 	}
+	groupRows()
+	renumberRows()
+	tallyQueue()
 	playlistTree.SetModel(playlistModel)
 
 } // end RemoveManyRowsfromCurrentPlaylist
@@ -490,6 +760,9 @@ func AddManyRowstoCurrentPlaylist(rows []*CurrentPLRow) {
 	for _, row := range rows {
 		AddRowtoCurrentPlaylist(row)
 	}
+	groupRows()
+	renumberRows()
+	tallyQueue()
 	playlistTree.SetModel(playlistModel)
 
 } // end AddManyRowstoCurrentPlaylist
@@ -501,7 +774,7 @@ func AddRowtoCurrentPlaylist(row *CurrentPLRow) {
 	playlistModel.Append(&iter)
 
 	if val, exists := currentArtworks[row.ArtworkPath]; exists {
-		playlistModel.Set(&iter, row.ID, row.ArtworkPath, val.pbufPointer.GPixbuf, escapeHTML(row.Name), escapeHTML(row.Artist), escapeHTML(row.Album))
+		playlistModel.Set(&iter, rowValues(row, val.pbufPointer.GPixbuf)...)
 		val.count++
 	} else {
 		pbuf, pbufErr := gdkpixbuf.NewFromFileAtSize(row.ArtworkPath, CUR_PL_ALBUM_SIZE, CUR_PL_ALBUM_SIZE)
@@ -509,7 +782,7 @@ func AddRowtoCurrentPlaylist(row *CurrentPLRow) {
 			log.ErrorReport("AddRowtoCurrentPlaylist()", "Could not load artwork ("+pbufErr.Error()+").")
 		} else {
 			currentArtworks[row.ArtworkPath] = &curArtWrkStorage{pbuf, 1}
-			playlistModel.Set(&iter, row.ID, row.ArtworkPath, pbuf.GPixbuf, escapeHTML(row.Name), escapeHTML(row.Artist), escapeHTML(row.Album))
+			playlistModel.Set(&iter, rowValues(row, pbuf.GPixbuf)...)
 		}
 	}
 
@@ -539,6 +812,9 @@ func BoldRowByReference(row *CurrentPLRow) {
 			strv = val.GetString()
 			playlistModel.SetValue(&iter, vali, removeBold(strv))
 		}
+		// Only the current song has a bitrate.
+		playlistModel.SetValue(&iter, CUR_PL_COL_BITRATE, "")
+		currentBitrate = 0
 		currentBoldRow.gref.Free()
 	}
 	currentBoldRow.gref = row.gref
@@ -554,6 +830,8 @@ func BoldRowByReference(row *CurrentPLRow) {
 		strv = val.GetString()
 		playlistModel.SetValue(&iter, vali, addBold(strv))
 	}
+	// What is left to play starts from here now.
+	tallyQueue()
 
 } // end BoldRowByReference
 
@@ -582,7 +860,7 @@ func BoldRowById(rowId int) {
 		ok = playlistModel.IterNext(&iter)
 	}
 
-	log.ErrorReport("BoldRowById()", "Never found row with ID "+string(rowId))
+	log.ErrorReport("BoldRowById()", "Never found row with ID "+strconv.Itoa(rowId))
 
 } // end BoldRowById
 
@@ -591,8 +869,11 @@ func ClearCurrentPlaylist() {
 
 	currentBoldRow.gref = nil
 	currentBoldRow.ID = -1
+	currentBitrate = 0
 	playlistModel.Clear()
-	playlistSortable.SetSortColumnId(gtk.TREE_SORTABLE_UNSORTED_SORT_COLUMN_ID, gtk.SORT_ASCENDING)
+	clearHeaderArtworks()
+	tallyQueue()
+	setSortIndicator(-1, false)
 
 	// In addition to cleaning up the model and all its
 	// references, the hashmap references need to be
@@ -603,3 +884,191 @@ func ClearCurrentPlaylist() {
 	}
 
 } // end ClearCurrentPlaylist
+
+// setSortIndicator shows which column (-1 for none) the current playlist is
+// sorted by, and in which direction.
+func setSortIndicator(column int, descending bool) {
+
+	playlistSortColumn, playlistSortDown = column, descending
+	for ci, col := range playlistCols {
+		col.SetSortIndicator(ci+CUR_PL_COL_NAME == column)
+		if descending {
+			col.SetSortOrder(gtk.SORT_DESCENDING)
+		} else {
+			col.SetSortOrder(gtk.SORT_ASCENDING)
+		}
+	}
+
+} // end setSortIndicator
+
+// ReorderCurrentPlaylist rearranges the current playlist view in the order
+// of the IDs given, as the server has just done, without reloading it.
+func ReorderCurrentPlaylist(ids []int) {
+
+	iters := make(map[int]*gtk.TreeIter)
+	var iter gtk.TreeIter
+	ok := playlistModel.GetIterFirst(&iter)
+	for ok {
+		var id glib.GValue
+		playlistModel.GetValue(&iter, CUR_PL_COL_ID, &id)
+		rowIter := iter
+		iters[id.GetInt()] = &rowIter
+		ok = playlistModel.IterNext(&iter)
+	}
+
+	// Rows keep their iters while moved, each is put after the one before.
+	var previous *gtk.TreeIter
+	for _, id := range ids {
+		rowIter, exists := iters[id]
+		if !exists {
+			continue
+		}
+		if previous == nil {
+			var first gtk.TreeIter
+			playlistModel.GetIterFirst(&first)
+			playlistModel.MoveBefore(rowIter, &first)
+		} else {
+			playlistModel.MoveAfter(rowIter, previous)
+		}
+		previous = rowIter
+	}
+	groupRows()
+	renumberRows()
+	tallyQueue()
+
+} // end ReorderCurrentPlaylist
+
+// SetRowRating changes the rating displayed on a row in the current playlist.
+func SetRowRating(row *CurrentPLRow, rating int) {
+
+	if row.gref != nil && row.gref.Valid() {
+		var iter gtk.TreeIter
+		path := row.gref.GetPath()
+		defer path.Free()
+		playlistModel.GetIter(&iter, path)
+		if row.ID == currentBoldRow.ID {
+			playlistModel.SetValue(&iter, CUR_PL_COL_RATING, addBold(ratingStars(rating)))
+		} else {
+			playlistModel.SetValue(&iter, CUR_PL_COL_RATING, ratingStars(rating))
+		}
+	}
+
+} // end SetRowRating
+
+// SetCurrentRating changes the rating displayed on the current song's row.
+func SetCurrentRating(rating int) {
+
+	SetRowRating(&currentBoldRow, rating)
+
+} // end SetCurrentRating
+
+// SetSmartPlaylists fills the smart playlists submenu, each playlist
+// getting its own submenu of actions.
+func SetSmartPlaylists(names []string) {
+
+	smartNames = names
+
+	if len(names) == 0 {
+		empty := gtk.NewMenuItemWithLabel("None Configured")
+		empty.SetSensitive(false)
+		smartMenu.Append(empty)
+	}
+
+	for _, name := range names {
+		item := gtk.NewMenuItemWithLabel(name)
+		actions := gtk.NewMenu()
+		add := gtk.NewMenuItemWithLabel("Add to Current Playlist")
+		actions.Append(add)
+		save := gtk.NewMenuItemWithLabel("Save as Stored Playlist")
+		actions.Append(save)
+		item.SetSubmenu(actions)
+		smartMenu.Append(item)
+		smartMenuAdds = append(smartMenuAdds, add)
+		smartMenuSaves = append(smartMenuSaves, save)
+	}
+	smartMenu.ShowAll()
+
+} // end SetSmartPlaylists
+
+// SetRadioStations fills the radio submenu with the names of the radio
+// bookmarks.
+func SetRadioStations(names []string) {
+
+	radioNames = names
+
+	if len(names) == 0 {
+		empty := gtk.NewMenuItemWithLabel("None Configured")
+		empty.SetSensitive(false)
+		radioMenu.Append(empty)
+	}
+
+	for _, name := range names {
+		item := gtk.NewMenuItemWithLabel(name)
+		radioMenu.Append(item)
+		radioMenuItems = append(radioMenuItems, item)
+	}
+	radioMenu.ShowAll()
+
+} // end SetRadioStations
+
+// SetServers fills the server menu with the names of the server profiles,
+// checking the one in use.
+func SetServers(names []string, current int) {
+
+	serverNames = names
+
+	for _, name := range names {
+		item := gtk.NewCheckMenuItemWithLabel(name)
+		item.SetDrawAsRadio(true)
+		serverMenu.Append(item)
+		serverMenuItems = append(serverMenuItems, item)
+	}
+	checkServer(current)
+
+	serverMenu.Append(gtk.NewSeparatorMenuItem())
+	sendItem := gtk.NewMenuItemWithLabel("Send Playback To")
+	sendMenu := gtk.NewMenu()
+	sendItem.SetSubmenu(sendMenu)
+	serverMenu.Append(sendItem)
+	groupItem := gtk.NewMenuItemWithLabel("Group With")
+	groupMenu := gtk.NewMenu()
+	groupItem.SetSubmenu(groupMenu)
+	serverMenu.Append(groupItem)
+	mirrorItem := gtk.NewMenuItemWithLabel("Mirror Queue To")
+	mirrorMenu := gtk.NewMenu()
+	mirrorItem.SetSubmenu(mirrorMenu)
+	serverMenu.Append(mirrorItem)
+	for _, name := range names {
+		send := gtk.NewMenuItemWithLabel(name)
+		sendMenu.Append(send)
+		serverMenuSends = append(serverMenuSends, send)
+		mirror := gtk.NewCheckMenuItemWithLabel(name)
+		mirrorMenu.Append(mirror)
+		serverMenuMirrors = append(serverMenuMirrors, mirror)
+		member := gtk.NewCheckMenuItemWithLabel(name)
+		groupMenu.Append(member)
+		serverMenuGroup = append(serverMenuGroup, member)
+	}
+	serverMenuStop = gtk.NewCheckMenuItemWithLabel("Stop Here After Sending")
+	serverMenu.Append(serverMenuStop)
+
+	serverMenu.ShowAll()
+
+} // end SetServers
+
+// checkServer checks the server profile in use (and only it).
+func checkServer(current int) {
+
+	currentServer = current
+	for i, item := range serverMenuItems {
+		item.SetActive(i == current)
+	}
+
+} // end checkServer
+
+// SetAutoDJ checks or unchecks the auto-DJ item in the main menu.
+func SetAutoDJ(enabled bool) {
+
+	mainMenuAutoDJ.SetActive(enabled)
+
+} // end SetAutoDJ
@@ -0,0 +1,187 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has the album view of the current playlist, where
+songs of the same album are grouped under a header row.
+*/
+
+package ui
+
+import (
+	"github.com/idealeric/juke/log"
+	"github.com/mattn/go-gtk/gdkpixbuf"
+	"github.com/mattn/go-gtk/glib"
+	"github.com/mattn/go-gtk/gtk"
+	"strings"
+)
+
+// Album header rows have this ID, songs never do (and -1 is no song).
+const ALBUM_HEADER_ID = -2
+
+// Size of the artwork on album header rows.
+const ALBUM_HEADER_ART_SIZE = 48
+
+var (
+	mainMenuAlbumView *gtk.CheckMenuItem           // Main menu item for the album view.
+	albumView         bool                         // Whether songs are grouped by album.
+	headerArtworks    map[string]*gdkpixbuf.Pixbuf // Artwork of the album headers, by path.
+)
+
+// albumGroup is a run of songs of the same album.
+type albumGroup struct {
+	first   gtk.TreeIter // first song
+	album   string
+	artist  string
+	year    string
+	seconds int
+	artwork string
+}
+
+// isHeader tells whether a row of the current playlist is an album header.
+func isHeader(iter *gtk.TreeIter) bool {
+
+	var id glib.GValue
+	playlistModel.GetValue(iter, CUR_PL_COL_ID, &id)
+	return id.GetInt() == ALBUM_HEADER_ID
+
+} // end isHeader
+
+// rowText returns a text column of a row, without the bolding.
+func rowText(iter *gtk.TreeIter, ci int) string {
+
+	var val glib.GValue
+	playlistModel.GetValue(iter, ci, &val)
+	return removeBold(val.GetString())
+
+} // end rowText
+
+// groupRows puts a header over every run of songs of the same album when
+// the album view is on (and takes them out when it is off). Songs only show
+// their own artwork out of the album view.
+func groupRows() {
+
+	var groups []*albumGroup
+	var group *albumGroup
+	var iter gtk.TreeIter
+	ok := playlistModel.GetIterFirst(&iter)
+	for ok {
+		if isHeader(&iter) {
+			// Removing moves on to the next row.
+			ok = playlistModel.Remove(&iter)
+			continue
+		}
+		playlistModel.SetValue(&iter, CUR_PL_COL_ARTSHOWN, !albumView)
+
+		album, artist := rowText(&iter, CUR_PL_COL_ALBUM), rowText(&iter, CUR_PL_COL_ALBUM_ARTIST)
+		if artist == "" {
+			artist = rowText(&iter, CUR_PL_COL_ARTIST)
+		}
+		if group == nil || album != group.album || artist != group.artist {
+			var artwork glib.GValue
+			playlistModel.GetValue(&iter, CUR_PL_COL_ARTPATH, &artwork)
+			group = &albumGroup{first: iter, album: album, artist: artist, artwork: artwork.GetString()}
+			groups = append(groups, group)
+		}
+		if group.year == "" {
+			group.year = rowText(&iter, CUR_PL_COL_DATE)
+			if len(group.year) > 4 {
+				group.year = group.year[:4]
+			}
+		}
+		var seconds glib.GValue
+		playlistModel.GetValue(&iter, CUR_PL_COL_SECONDS, &seconds)
+		if seconds.GetInt() > 0 {
+			group.seconds += seconds.GetInt()
+		}
+		ok = playlistModel.IterNext(&iter)
+	}
+
+	if !albumView {
+		return
+	}
+	// Songs keep their iters as headers are inserted.
+	for _, group := range groups {
+		var header gtk.TreeIter
+		playlistModel.InsertBefore(&header, &group.first)
+		playlistModel.SetValue(&header, CUR_PL_COL_ID, ALBUM_HEADER_ID)
+		playlistModel.SetValue(&header, CUR_PL_COL_ARTSHOWN, true)
+		if pbuf := headerArtwork(group.artwork); pbuf != nil {
+			playlistModel.SetValue(&header, CUR_PL_COL_ARTBUF, pbuf.GPixbuf)
+		}
+		playlistModel.SetValue(&header, CUR_PL_COL_NAME, headerMarkup(group))
+	}
+
+} // end groupRows
+
+// headerMarkup describes an album on its header row. The album and artist
+// come from the model, so they are already escaped.
+func headerMarkup(group *albumGroup) string {
+
+	album := group.album
+	if album == "" {
+		album = "Unknown Album"
+	}
+	var details []string
+	if group.artist != "" {
+		details = append(details, group.artist)
+	}
+	if group.year != "" {
+		details = append(details, group.year)
+	}
+	details = append(details, formatDuration(group.seconds))
+	return "<span size=\"large\" font_weight=\"bold\">" + album + "</span>\n" + strings.Join(details, " · ")
+
+} // end headerMarkup
+
+// headerArtwork loads the artwork of an album header, once per path.
+func headerArtwork(artPath string) *gdkpixbuf.Pixbuf {
+
+	if headerArtworks == nil {
+		headerArtworks = make(map[string]*gdkpixbuf.Pixbuf)
+	}
+	if pbuf, exists := headerArtworks[artPath]; exists {
+		return pbuf
+	}
+	pbuf, pbufErr := gdkpixbuf.NewFromFileAtSize(artPath, ALBUM_HEADER_ART_SIZE, ALBUM_HEADER_ART_SIZE)
+	if pbufErr != nil {
+		log.ErrorReport("headerArtwork()", "Could not load artwork ("+pbufErr.Error()+").")
+		return nil
+	}
+	headerArtworks[artPath] = pbuf
+	return pbuf
+
+} // end headerArtwork
+
+// clearHeaderArtworks frees the album header artworks.
+func clearHeaderArtworks() {
+
+	for artPath, pbuf := range headerArtworks {
+		pbuf.Unref()
+		delete(headerArtworks, artPath)
+	}
+
+} // end clearHeaderArtworks
+
+// SetAlbumView turns the album view of the current playlist on or off.
+func SetAlbumView(enabled bool) {
+
+	mainMenuAlbumView.SetActive(enabled)
+	applyAlbumView(enabled)
+
+} // end SetAlbumView
+
+// applyAlbumView regroups the current playlist if the view has changed.
+func applyAlbumView(enabled bool) {
+
+	if enabled == albumView {
+		return
+	}
+	albumView = enabled
+	playlistTree.SetModel(nil)
+	groupRows()
+	playlistTree.SetModel(playlistModel)
+	if !enabled {
+		clearHeaderArtworks()
+	}
+
+} // end applyAlbumView
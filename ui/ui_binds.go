@@ -5,6 +5,7 @@ import (
 	"github.com/mattn/go-gtk/gdk"
 	"github.com/mattn/go-gtk/glib"
 	"github.com/mattn/go-gtk/gtk"
+	"strings"
 	"unsafe"
 )
 
@@ -32,6 +33,8 @@ func NextClick(f func() error) {
 		callBackCheckandCheckforError(f, cntx)
 	})
 
+	registerCommand("next", "Next song", func() bool { return pressButton(NEXT_BUTTON) })
+
 } // end NextClick
 
 // PreviousClick will bind to the "release" event on the previous button.
@@ -41,6 +44,8 @@ func PreviousClick(f func() error) {
 		callBackCheckandCheckforError(f, cntx)
 	})
 
+	registerCommand("previous", "Previous song", func() bool { return pressButton(PREV_BUTTON) })
+
 } // end PreviousClick
 
 // PlayPauseClick will bind to the "release" event on the play/pause button.
@@ -50,6 +55,8 @@ func PlayPauseClick(f func() error) {
 		callBackCheckandCheckforError(f, cntx)
 	})
 
+	registerCommand("play_pause", "Play or pause", func() bool { return pressButton(PLAY_PAUSE_BUTTON) })
+
 } // end PlayPauseClick
 
 // StopClick will bind to the "release" event on the stop button.
@@ -59,30 +66,47 @@ func StopClick(f func() error) {
 		callBackCheckandCheckforError(f, cntx)
 	})
 
+	registerCommand("stop", "Stop", func() bool { return pressButton(STOP_BUTTON) })
+
 } // end StopClick
 
-// ConnectionClick will bind to the "click" event on the connection button.
+// KeySeek will bind to the seek keyboard shortcuts, which seek the current
+// song by some seconds.
+func KeySeek(f func(int) error) {
+
+	keySeek = f
+	registerCommand("seek_forward", "Seek forward", func() bool { return seekBy(SEEK_STEP) })
+	registerCommand("seek_backward", "Seek backward", func() bool { return seekBy(-SEEK_STEP) })
+
+} // end KeySeek
+
+// ConnectionClick will bind to the reconnect item in the server menu.
 func ConnectionClick(f func() error) {
 
-	rightControls[CONNECTION_BUTTON].Connect("released", func(cntx *glib.CallbackContext) {
+	serverMenuReconnect.Connect("activate", func(cntx *glib.CallbackContext) {
 		callBackCheckandCheckforError(f, cntx)
 	})
 
+	registerCommand("reconnect", "Reconnect to the server", func() bool { return activateServerItem(serverMenuReconnect) })
+
 } // end ConnectionClick
 
-// ProgressBarClick will bind to the "click" event on the progress bar.
+// ProgressBarClick will bind to a click (or the end of a drag) on the
+// progress bar, which seeks to the point clicked.
 func ProgressBarClick(f func(int, int) error) {
 
-	progressBarEvent.Connect("button_press_event", func(cntx *glib.CallbackContext) {
-		arg := cntx.Args(0)
-		eventButton := *(**gdk.EventButton)(unsafe.Pointer(&arg))
-		if err := f(int(eventButton.X), progressBar.GetAllocation().Width); err != nil {
-			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
-		}
-	})
+	progressSeek = f
 
 } // end ProgressBarClick
 
+// RemainingToggle will bind to the switch between the time elapsed and the
+// time remaining on the progress bar.
+func RemainingToggle(f func(bool) error) {
+
+	remainingToggled = f
+
+} // end RemainingToggle
+
 // CurrentRowDoubleClick will bind to the "double-click" event on a row in
 // the current playlist.
 func CurrentRowDoubleClick(f func(*CurrentPLRow) error) {
@@ -96,6 +120,13 @@ func CurrentRowDoubleClick(f func(*CurrentPLRow) error) {
 		)
 		playlistTree.GetCursor(&path, &col)
 		playlistModel.GetIter(&iter, path)
+		if isHeader(&iter) {
+			// An album header plays the album from its first song.
+			if !playlistModel.IterNext(&iter) {
+				return
+			}
+			path = playlistModel.GetPath(&iter)
+		}
 		playlistModel.GetValue(&iter, CUR_PL_COL_ID, &val)
 		if err := f(&CurrentPLRow{ID: val.GetInt(), gref: gtk.NewTreeRowReference(playlistModel, path)}); err != nil {
 			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
@@ -105,31 +136,30 @@ func CurrentRowDoubleClick(f func(*CurrentPLRow) error) {
 } // end CurrentRowDoubleClick
 
 // CurrentColumnClick will bind to the "column click" event in the
-// current playlist.
-func CurrentColumnClick(f func(chan *CurrentPLRow) error) {
-
-	for _, c := range playlistCols {
-		c.Connect("clicked", func(cntx *glib.CallbackContext) {
-
-			rowsChan := make(chan *CurrentPLRow, ROW_BUFFER_SIZE)
-
-			go func() {
-				var iter gtk.TreeIter
-				ok := playlistModel.GetIterFirst(&iter)
-				for ok {
-					var id glib.GValue
-					playlistModel.GetValue(&iter, CUR_PL_COL_ID, &id)
-					rowsChan <- &CurrentPLRow{ID: id.GetInt()}
-					ok = playlistModel.IterNext(&iter)
-				}
-				close(rowsChan)
-			}()
-
-			if err := f(rowsChan); err != nil {
+// current playlist. The keys to sort by are passed along, and whether to
+// sort descending (clicking the same column again reverses the order).
+func CurrentColumnClick(f func([]SortKey, bool) error) {
+
+	for ci, c := range playlistCols {
+		column := ci + CUR_PL_COL_NAME
+		sortBy := func() {
+			descending := column == playlistSortColumn && !playlistSortDown
+			setSortIndicator(column, descending)
+			if err := f(columnSortKeys[column], descending); err != nil {
 				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
 			}
-
+		}
+		c.Connect("clicked", func(cntx *glib.CallbackContext) {
+			sortBy()
 		})
+		if len(columnSortKeys[column]) > 0 {
+			registerCommand("sort_"+playlistColumns[ci].name, "Sort by "+playlistColumns[ci].title, func() bool {
+				if playlistTree.GetSensitive() {
+					sortBy()
+				}
+				return true
+			})
+		}
 	} // end for range of columns
 
 } // end CurrentColumnClick
@@ -150,7 +180,7 @@ func CurrentRemoveSongs(f func(chan *CurrentPLRow) error) {
 				path := playlistModel.GetPath(&iter)
 				defer path.Free()
 
-				if playlistSelection.PathIsSelected(path) {
+				if playlistSelection.PathIsSelected(path) && !isHeader(&iter) {
 					var id glib.GValue
 					playlistModel.GetValue(&iter, CUR_PL_COL_ID, &id)
 					rowsChan <- &CurrentPLRow{ID: id.GetInt(), gref: gtk.NewTreeRowReference(playlistModel, path)}
@@ -168,6 +198,8 @@ func CurrentRemoveSongs(f func(chan *CurrentPLRow) error) {
 
 	})
 
+	registerCommand("remove", "Remove the selected songs", func() bool { return activatePlaylistItem(playlistMenuRemove) })
+
 } // end CurrentRemoveSongs
 
 // CurrentClearSongs will bind the "click" event on the
@@ -178,4 +210,617 @@ func CurrentClearSongs(f func() error) {
 		callBackCheckandCheckforError(f, cntx)
 	})
 
+	registerCommand("clear", "Clear the playlist", func() bool { return activatePlaylistItem(playlistMenuClear) })
+
 } // end CurrentClearSongs
+
+// CurrentUndo will bind to the undo and redo items in the current playlist
+// menu, passing true for redo.
+func CurrentUndo(f func(bool) error) {
+
+	playlistMenuUndo.Connect("activate", func(cntx *glib.CallbackContext) {
+		if err := f(false); err != nil {
+			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+		}
+	})
+	playlistMenuRedo.Connect("activate", func(cntx *glib.CallbackContext) {
+		if err := f(true); err != nil {
+			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+		}
+	})
+
+	registerCommand("undo", "Undo the last playlist edit", func() bool {
+		if undoAvailable {
+			activatePlaylistItem(playlistMenuUndo)
+		}
+		return true
+	})
+	registerCommand("redo", "Redo the last undone edit", func() bool {
+		if redoAvailable {
+			activatePlaylistItem(playlistMenuRedo)
+		}
+		return true
+	})
+
+} // end CurrentUndo
+
+// StatisticsOpen will bind to the statistics item in the main menu as well
+// as to a change of the time range in the statistics window.
+func StatisticsOpen(f func(StatsRange) error) {
+
+	mainMenuStatistics.Connect("activate", func(cntx *glib.CallbackContext) {
+		if err := f(StatsRange(statsRangeCombo.GetActive())); err != nil {
+			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+		}
+	})
+
+	statsRangeCombo.Connect("changed", func(cntx *glib.CallbackContext) {
+		if err := f(StatsRange(statsRangeCombo.GetActive())); err != nil {
+			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+		}
+	})
+
+	registerCommand("statistics", "Show the listening statistics", func() bool { return activateItem(mainMenuStatistics) })
+
+} // end StatisticsOpen
+
+// StatisticsRowDoubleClick will bind to the "double-click" event on a row
+// in the recently played list. The song's file is passed along.
+func StatisticsRowDoubleClick(f func(string) error) {
+
+	statsRecentTree.Connect("row-activated", func(cntx *glib.CallbackContext) {
+		var (
+			path *gtk.TreePath
+			iter gtk.TreeIter
+			val  glib.GValue
+			col  *gtk.TreeViewColumn
+		)
+		statsRecentTree.GetCursor(&path, &col)
+		statsRecentModel.GetIter(&iter, path)
+		statsRecentModel.GetValue(&iter, HISTORY_COL_FILE, &val)
+		if err := f(val.GetString()); err != nil {
+			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+		}
+	})
+
+} // end StatisticsRowDoubleClick
+
+// ScrobbleExport will bind to the scrobble export items in the main menu.
+// The user is asked for a filename first; nothing happens if they cancel.
+func ScrobbleExport(f func(ScrobbleFormat, string) error) {
+
+	mainMenuRockbox.Connect("activate", func(cntx *glib.CallbackContext) {
+		if filename := chooseSaveFilename("Export Scrobbles (Rockbox)", ".scrobbler.log"); filename != "" {
+			if err := f(SCROBBLE_ROCKBOX, filename); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		}
+	})
+
+	mainMenuLBrainz.Connect("activate", func(cntx *glib.CallbackContext) {
+		if filename := chooseSaveFilename("Export Scrobbles (ListenBrainz)", "listens.json"); filename != "" {
+			if err := f(SCROBBLE_LISTENBRAINZ, filename); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		}
+	})
+
+	registerCommand("export_rockbox", "Export scrobbles (Rockbox)", func() bool { return activateItem(mainMenuRockbox) })
+	registerCommand("export_listenbrainz", "Export scrobbles (ListenBrainz)", func() bool { return activateItem(mainMenuLBrainz) })
+
+} // end ScrobbleExport
+
+// CurrentRatingClick will bind to a click on the rating column of the
+// current playlist. The rating passed along is determined by which star was
+// clicked; clicking the only lit star clears the rating.
+func CurrentRatingClick(f func(*CurrentPLRow, int) error) {
+
+	playlistTree.Connect("button-press-event", func(cntx *glib.CallbackContext) bool {
+		arg := cntx.Args(0)
+		eventButton := *(**gdk.EventButton)(unsafe.Pointer(&arg))
+		if eventButton.Button != 1 {
+			return false
+		}
+
+		var (
+			path   gtk.TreePath
+			col    gtk.TreeViewColumn
+			iter   gtk.TreeIter
+			cellX  int
+			cellY  int
+			id     glib.GValue
+			file   glib.GValue
+			rating glib.GValue
+		)
+		ratingCol := playlistCols[CUR_PL_COL_RATING-CUR_PL_COL_NAME]
+		if !ratingCol.GetVisible() || !playlistTree.GetPathAtPos(int(eventButton.X), int(eventButton.Y), &path, &col, &cellX, &cellY) {
+			return false
+		}
+		if col.GetTitle() != ratingCol.GetTitle() || ratingCol.GetWidth() <= 0 {
+			return false
+		}
+
+		playlistModel.GetIter(&iter, &path)
+		if isHeader(&iter) {
+			return false
+		}
+		playlistModel.GetValue(&iter, CUR_PL_COL_ID, &id)
+		playlistModel.GetValue(&iter, CUR_PL_COL_FILE, &file)
+		playlistModel.GetValue(&iter, CUR_PL_COL_RATING, &rating)
+		newRating := cellX*CUR_PL_MAX_RATING/ratingCol.GetWidth() + 1
+		if newRating == 1 && removeBold(rating.GetString()) == ratingStars(1) {
+			newRating = 0
+		}
+
+		row := &CurrentPLRow{ID: id.GetInt(), File: file.GetString(), gref: gtk.NewTreeRowReference(playlistModel, &path)}
+		if err := f(row, newRating); err != nil {
+			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+		}
+		return true
+	})
+
+} // end CurrentRatingClick
+
+// SmartPlaylistClick will bind to the actions of every smart playlist in
+// the main menu. The index of the playlist (as given to SetSmartPlaylists)
+// is passed along, as is whether it is to be saved rather than added.
+func SmartPlaylistClick(f func(int, bool) error) {
+
+	for i := range smartMenuAdds {
+		index := i
+		smartMenuAdds[i].Connect("activate", func(cntx *glib.CallbackContext) {
+			if err := f(index, false); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		})
+		smartMenuSaves[i].Connect("activate", func(cntx *glib.CallbackContext) {
+			if err := f(index, true); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		})
+		registerCommand("smart_playlist:"+smartNames[i], "Add smart playlist: "+smartNames[i], func() bool { return activateItem(smartMenuAdds[index]) })
+		registerCommand("save_smart_playlist:"+smartNames[i], "Save smart playlist: "+smartNames[i], func() bool { return activateItem(smartMenuSaves[index]) })
+	}
+
+} // end SmartPlaylistClick
+
+// RadioClick will bind to every radio bookmark in the main menu. The index
+// of the bookmark (as given to SetRadioStations) is passed along.
+func RadioClick(f func(int) error) {
+
+	for i := range radioMenuItems {
+		index := i
+		radioMenuItems[i].Connect("activate", func(cntx *glib.CallbackContext) {
+			if err := f(index); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		})
+		registerCommand("radio:"+radioNames[i], "Add radio station: "+radioNames[i], func() bool { return activateItem(radioMenuItems[index]) })
+	}
+
+} // end RadioClick
+
+// AutoDJToggle will bind to the "toggle" event on the auto-DJ item in the
+// main menu. Whether the auto-DJ is now enabled is passed along.
+func AutoDJToggle(f func(bool) error) {
+
+	mainMenuAutoDJ.Connect("toggled", func(cntx *glib.CallbackContext) {
+		if err := f(mainMenuAutoDJ.GetActive()); err != nil {
+			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+		}
+	})
+
+	registerCommand("auto_dj", "Turn the auto-DJ on or off", func() bool { return activateItem(&mainMenuAutoDJ.MenuItem) })
+
+} // end AutoDJToggle
+
+// PartyToggle will bind to the "toggle" event on the party mode item in
+// the main menu. Whether party mode is now enabled is passed along.
+func PartyToggle(f func(bool) error) {
+
+	mainMenuParty.Connect("toggled", func(cntx *glib.CallbackContext) {
+		if err := f(mainMenuParty.GetActive()); err != nil {
+			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+		}
+	})
+
+	registerCommand("party_mode", "Turn party mode on or off", func() bool { return activateItem(&mainMenuParty.MenuItem) })
+
+} // end PartyToggle
+
+// PartyDecide will bind to the approve and reject buttons in the party
+// requests window. The selected request's ID is passed along, as is
+// whether it was approved.
+func PartyDecide(f func(int, bool) error) {
+
+	partyApprove.Connect("clicked", func(cntx *glib.CallbackContext) {
+		if id, selected := selectedPartyRequest(); selected {
+			if err := f(id, true); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		}
+	})
+
+	partyReject.Connect("clicked", func(cntx *glib.CallbackContext) {
+		if id, selected := selectedPartyRequest(); selected {
+			if err := f(id, false); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		}
+	})
+
+} // end PartyDecide
+
+// ServerSwitch will bind to the profiles in the server menu. The index of
+// the chosen profile (as given to SetServers) is passed along.
+func ServerSwitch(f func(int) error) {
+
+	for i := range serverMenuItems {
+		index := i
+		serverMenuItems[i].Connect("activate", func(cntx *glib.CallbackContext) {
+			// Checking and unchecking items activates them too, so only
+			// a newly chosen profile is switched to.
+			if index == currentServer || !serverMenuItems[index].GetActive() {
+				if index == currentServer && !serverMenuItems[index].GetActive() {
+					serverMenuItems[index].SetActive(true)
+				}
+				return
+			}
+			checkServer(index)
+			if err := f(index); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		})
+		registerCommand("server:"+serverNames[i], "Switch to server: "+serverNames[i], func() bool {
+			if index != currentServer {
+				activateServerItem(&serverMenuItems[index].MenuItem)
+			}
+			return true
+		})
+	}
+
+} // end ServerSwitch
+
+// SendPlayback will bind to the profiles in the "Send Playback To" submenu
+// of the server menu. The index of the profile (as given to SetServers) is
+// passed along, as is whether playback is to stop here.
+func SendPlayback(f func(int, bool) error) {
+
+	for i := range serverMenuSends {
+		index := i
+		serverMenuSends[i].Connect("activate", func(cntx *glib.CallbackContext) {
+			if err := f(index, serverMenuStop.GetActive()); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		})
+		registerCommand("send_playback:"+serverNames[i], "Send playback to: "+serverNames[i], func() bool { return activateServerItem(serverMenuSends[index]) })
+	}
+
+} // end SendPlayback
+
+// MirrorToggle will bind to the profiles in the "Mirror Queue To" submenu
+// of the server menu. The index of the profile (as given to SetServers) is
+// passed along, as is whether mirroring to it is now enabled.
+func MirrorToggle(f func(int, bool) error) {
+
+	for i := range serverMenuMirrors {
+		index := i
+		serverMenuMirrors[i].Connect("toggled", func(cntx *glib.CallbackContext) {
+			if err := f(index, serverMenuMirrors[index].GetActive()); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		})
+		registerCommand("mirror:"+serverNames[i], "Mirror the queue to: "+serverNames[i], func() bool { return activateServerItem(&serverMenuMirrors[index].MenuItem) })
+	}
+
+} // end MirrorToggle
+
+// GroupToggle will bind to the profiles in the "Group With" submenu of the
+// server menu. The index of the profile (as given to SetServers) is passed
+// along, as is whether it is now in the group.
+func GroupToggle(f func(int, bool) error) {
+
+	for i := range serverMenuGroup {
+		index := i
+		serverMenuGroup[i].Connect("toggled", func(cntx *glib.CallbackContext) {
+			if err := f(index, serverMenuGroup[index].GetActive()); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		})
+		registerCommand("group:"+serverNames[i], "Group with: "+serverNames[i], func() bool { return activateServerItem(&serverMenuGroup[index].MenuItem) })
+	}
+
+} // end GroupToggle
+
+// VolumeChange will bind to the "value-changed" event on the volume scale.
+// The new volume is passed along.
+func VolumeChange(f func(int) error) {
+
+	volumeScale.Connect("value-changed", func(cntx *glib.CallbackContext) {
+		// Juke showing the server's volume is not a change.
+		if volumeSetting {
+			return
+		}
+		if err := f(int(volumeScale.GetValue())); err != nil {
+			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+		}
+	})
+
+	registerCommand("volume_up", "Volume up", func() bool { return changeVolume(VOLUME_STEP) })
+	registerCommand("volume_down", "Volume down", func() bool { return changeVolume(-VOLUME_STEP) })
+
+} // end VolumeChange
+
+// OutputsOpen will bind to the audio outputs item in the main menu.
+func OutputsOpen(f func() error) {
+
+	mainMenuOutputs.Connect("activate", func(cntx *glib.CallbackContext) {
+		callBackCheckandCheckforError(f, cntx)
+	})
+
+	registerCommand("outputs", "Show the audio outputs", func() bool { return activateItem(mainMenuOutputs) })
+
+} // end OutputsOpen
+
+// OutputChange will bind to the enable, disable and toggle buttons in the
+// audio outputs window. The selected output's ID and the action are
+// passed along.
+func OutputChange(f func(int, OutputAction) error) {
+
+	for i := range outputsButtons {
+		action := OutputAction(i)
+		outputsButtons[i].Connect("clicked", func(cntx *glib.CallbackContext) {
+			if id, selected := selectedOutput(); selected {
+				if err := f(id, action); err != nil {
+					log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+				}
+			}
+		})
+	}
+
+} // end OutputChange
+
+// OutputMove will bind to the move button in the audio outputs window. The
+// selected output's name and the chosen partition are passed along.
+func OutputMove(f func(string, string) error) {
+
+	outputsMove.Connect("clicked", func(cntx *glib.CallbackContext) {
+		if name, selected := selectedOutputName(); selected && outputsPartition.GetActiveText() != "" {
+			if err := f(name, outputsPartition.GetActiveText()); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		}
+	})
+
+} // end OutputMove
+
+// PartitionsOpen will bind to the partitions item in the server menu.
+func PartitionsOpen(f func() error) {
+
+	serverMenuPartition.Connect("activate", func(cntx *glib.CallbackContext) {
+		callBackCheckandCheckforError(f, cntx)
+	})
+
+	registerCommand("partitions", "Show the partitions", func() bool { return activateServerItem(serverMenuPartition) })
+
+} // end PartitionsOpen
+
+// PartitionSwitch will bind to the switch button in the partitions window.
+// The selected partition is passed along.
+func PartitionSwitch(f func(string) error) {
+
+	partitionsSwitch.Connect("clicked", func(cntx *glib.CallbackContext) {
+		if partition, selected := selectedPartition(); selected {
+			if err := f(partition); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		}
+	})
+
+} // end PartitionSwitch
+
+// PartitionDelete will bind to the delete button in the partitions window.
+// The selected partition is passed along.
+func PartitionDelete(f func(string) error) {
+
+	partitionsDelete.Connect("clicked", func(cntx *glib.CallbackContext) {
+		if partition, selected := selectedPartition(); selected {
+			if err := f(partition); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		}
+	})
+
+} // end PartitionDelete
+
+// PartitionNew will bind to the new partition button in the partitions
+// window. The name entered is passed along.
+func PartitionNew(f func(string) error) {
+
+	partitionsNew.Connect("clicked", func(cntx *glib.CallbackContext) {
+		if name := strings.TrimSpace(partitionsName.GetText()); name != "" {
+			partitionsName.SetText("")
+			if err := f(name); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		}
+	})
+
+} // end PartitionNew
+
+// SettingsOpen will bind to the playback settings item in the main menu.
+func SettingsOpen(f func() error) {
+
+	mainMenuSettings.Connect("activate", func(cntx *glib.CallbackContext) {
+		callBackCheckandCheckforError(f, cntx)
+	})
+
+	registerCommand("playback_settings", "Show the playback settings", func() bool { return activateItem(mainMenuSettings) })
+
+} // end SettingsOpen
+
+// SettingChange will bind to every control in the playback settings window.
+// The setting changed and its new value are passed along (the delay is
+// negative when MixRamp is turned off, the ReplayGain mode is passed as
+// its index in REPLAY_GAIN_MODES).
+func SettingChange(f func(PlaybackSetting, float64) error) {
+
+	changed := func(setting PlaybackSetting, value float64) {
+		// Juke showing the server's settings is not a change.
+		if settingsUpdating {
+			return
+		}
+		if err := f(setting, value); err != nil {
+			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+		}
+	}
+	mixRampDelay := func() {
+		if settingsMixRamp.GetActive() {
+			changed(SETTING_MIXRAMP_DELAY, settingsMixRampDelay.GetValue())
+		} else {
+			changed(SETTING_MIXRAMP_DELAY, -1)
+		}
+	}
+
+	settingsCrossfade.Connect("value-changed", func(cntx *glib.CallbackContext) {
+		changed(SETTING_CROSSFADE, settingsCrossfade.GetValue())
+	})
+	settingsMixRampDB.Connect("value-changed", func(cntx *glib.CallbackContext) {
+		changed(SETTING_MIXRAMP_DB, settingsMixRampDB.GetValue())
+	})
+	settingsMixRampDelay.Connect("value-changed", func(cntx *glib.CallbackContext) {
+		mixRampDelay()
+	})
+	settingsMixRamp.Connect("toggled", func(cntx *glib.CallbackContext) {
+		mixRampDelay()
+	})
+	settingsReplayGain.Connect("changed", func(cntx *glib.CallbackContext) {
+		if active := settingsReplayGain.GetActive(); active >= 0 {
+			changed(SETTING_REPLAY_GAIN, float64(active))
+		}
+	})
+
+} // end SettingChange
+
+// selectedIds returns the IDs of the selected rows in the current playlist,
+// in playlist order.
+func selectedIds() []int {
+
+	var ids []int
+	var iter gtk.TreeIter
+	ok := playlistModel.GetIterFirst(&iter)
+	for ok {
+		path := playlistModel.GetPath(&iter)
+		if playlistSelection.PathIsSelected(path) && !isHeader(&iter) {
+			var id glib.GValue
+			playlistModel.GetValue(&iter, CUR_PL_COL_ID, &id)
+			ids = append(ids, id.GetInt())
+		}
+		path.Free()
+		ok = playlistModel.IterNext(&iter)
+	}
+	return ids
+
+} // end selectedIds
+
+// CurrentQueueOperation will bind to the queue operations in the current
+// playlist menu. The operation, the priority (for QUEUE_PRIORITY) and the
+// IDs of the selected rows are passed along.
+func CurrentQueueOperation(f func(QueueOperation, int, []int) error) {
+
+	operate := func(operation QueueOperation, priority int) {
+		if ids := selectedIds(); len(ids) > 0 {
+			if err := f(operation, priority, ids); err != nil {
+				log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+			}
+		}
+	}
+
+	queueCommands := []struct{ name, title string }{
+		{"play_next", "Play the selected songs next"},
+		{"move_to_top", "Move the selected songs to the top"},
+		{"move_to_bottom", "Move the selected songs to the bottom"},
+		{"crop", "Crop the playlist to the selected songs"},
+		{"shuffle_selection", "Shuffle the selected songs"}}
+	for i := range playlistMenuQueue {
+		operation := QueueOperation(i)
+		item := playlistMenuQueue[i]
+		item.Connect("activate", func(cntx *glib.CallbackContext) {
+			operate(operation, 0)
+		})
+		registerCommand(queueCommands[i].name, queueCommands[i].title, func() bool { return activatePlaylistItem(item) })
+	}
+	for i := range playlistMenuPrios {
+		priority := PRIORITY_LEVELS[i].Priority
+		item := playlistMenuPrios[i]
+		item.Connect("activate", func(cntx *glib.CallbackContext) {
+			operate(QUEUE_PRIORITY, priority)
+		})
+		label := PRIORITY_LEVELS[i].Label
+		registerCommand("priority_"+strings.ToLower(label), "Set the priority of the selected songs: "+label, func() bool { return activatePlaylistItem(item) })
+	}
+
+} // end CurrentQueueOperation
+
+// ColumnsChange will bind to the column chooser in the current playlist
+// menu and to columns being dragged around, passing the new layout.
+func ColumnsChange(f func([]ColumnSetting) error) {
+
+	changed := func() {
+		if columnsUpdating {
+			return
+		}
+		if err := f(currentLayout()); err != nil {
+			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+		}
+	}
+
+	for i := range playlistMenuColumns {
+		ci := i
+		playlistMenuColumns[ci].Connect("toggled", func(cntx *glib.CallbackContext) {
+			if columnsUpdating {
+				return
+			}
+			columnShown[ci] = playlistMenuColumns[ci].GetActive()
+			showColumn(ci)
+			changed()
+		})
+	}
+	playlistTree.Connect("columns-changed", func(cntx *glib.CallbackContext) {
+		changed()
+	})
+
+} // end ColumnsChange
+
+// AlbumViewToggle will bind to the "toggle" event on the album view item in
+// the main menu. The view is changed right away, whether it is now on is
+// passed along.
+func AlbumViewToggle(f func(bool) error) {
+
+	mainMenuAlbumView.Connect("toggled", func(cntx *glib.CallbackContext) {
+		applyAlbumView(mainMenuAlbumView.GetActive())
+		if err := f(mainMenuAlbumView.GetActive()); err != nil {
+			log.ErrorReport("UI callBackCheckandCheckforError()", err.Error()+".")
+		}
+	})
+
+	registerCommand("album_view", "Group the playlist by album", func() bool { return activateItem(&mainMenuAlbumView.MenuItem) })
+
+} // end AlbumViewToggle
+
+// PaletteSearch will bind to the query of the command palette, to search
+// the library. The query is passed along, the songs found are to be given
+// back to SetPaletteSongs.
+func PaletteSearch(f func(string) error) {
+
+	paletteSearch = f
+
+} // end PaletteSearch
+
+// PaletteAddSong will bind to the songs of the library in the command
+// palette. The song's file is passed along.
+func PaletteAddSong(f func(string) error) {
+
+	paletteAdd = f
+
+} // end PaletteAddSong
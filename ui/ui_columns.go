@@ -0,0 +1,239 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has the columns of the current playlist: which are
+shown, in what order and how wide.
+*/
+
+package ui
+
+import (
+	"github.com/mattn/go-gtk/glib"
+	"github.com/mattn/go-gtk/gtk"
+	"strconv"
+	"strings"
+)
+
+// The narrowest a column can be made.
+const COLUMN_MIN_WIDTH = 30
+
+// playlistColumn describes a column of the current playlist.
+type playlistColumn struct {
+	name  string // as kept in the configuration
+	title string // as shown in the header
+	width int    // default width
+}
+
+// The columns, from CUR_PL_COL_NAME on.
+var playlistColumns = []playlistColumn{
+	{"name", "Name", 370},
+	{"artist", "Artist", 190},
+	{"album", "Album", 190},
+	{"rating", "Rating", 80},
+	{"priority", "Priority", 70},
+	{"track", "Track", 50},
+	{"disc", "Disc", 40},
+	{"duration", "Duration", 70},
+	{"genre", "Genre", 120},
+	{"date", "Date", 90},
+	{"composer", "Composer", 150},
+	{"performer", "Performer", 150},
+	{"album_artist", "Album Artist", 190},
+	{"file", "File", 300},
+	{"position", "#", 40},
+	{"bitrate", "Bitrate", 80}}
+
+// ColumnSetting is how a column is laid out, by its configuration name.
+type ColumnSetting struct {
+	Name    string
+	Visible bool
+	Width   int
+}
+
+var (
+	playlistMenuColumns []*gtk.CheckMenuItem // Treeview popup menu items to show columns (by column).
+	columnShown         []bool               // Whether the user has each column shown.
+	columnsUpdating     bool                 // Set while the layout is applied, so that it is not reported back.
+	ratingsAvailable    bool                 // Whether the server can keep ratings.
+	currentBitrate      int                  // Bitrate shown on the current song's row.
+	exitLayout          []ColumnSetting      // The layout when the window was closed.
+)
+
+// initColumnsMenu adds the column chooser to the current playlist menu.
+func initColumnsMenu(playlistMenu *gtk.Menu) {
+
+	columnShown = make([]bool, len(playlistColumns))
+	columnsItem := gtk.NewMenuItemWithLabel("Columns")
+	columnsMenu := gtk.NewMenu()
+	for _, column := range playlistColumns {
+		item := gtk.NewCheckMenuItemWithLabel(column.title)
+		columnsMenu.Append(item)
+		playlistMenuColumns = append(playlistMenuColumns, item)
+	}
+	columnsItem.SetSubmenu(columnsMenu)
+	playlistMenu.Append(columnsItem)
+
+	// The widths are only final once the window is closed.
+	window.Connect("delete-event", func() bool {
+		exitLayout = currentLayout()
+		return false
+	})
+
+} // end initColumnsMenu
+
+// showColumn shows or hides a column, the rating column only showing when
+// the server can keep ratings.
+func showColumn(ci int) {
+
+	visible := columnShown[ci] && (ci+CUR_PL_COL_NAME != CUR_PL_COL_RATING || ratingsAvailable)
+	if !visible && playlistCols[ci].GetVisible() && playlistCols[ci].GetWidth() >= COLUMN_MIN_WIDTH {
+		// The width is kept for when the column is shown again.
+		playlistCols[ci].SetFixedWidth(playlistCols[ci].GetWidth())
+	}
+	playlistCols[ci].SetVisible(visible)
+
+} // end showColumn
+
+// SetColumnLayout applies a layout to the current playlist. Columns the
+// layout does not mention are hidden and put last.
+func SetColumnLayout(layout []ColumnSetting) {
+
+	columnsUpdating = true
+	defer func() { columnsUpdating = false }()
+
+	var order []int
+	placed := make([]bool, len(playlistColumns))
+	for _, setting := range layout {
+		for ci, column := range playlistColumns {
+			if column.name == setting.Name && !placed[ci] {
+				placed[ci] = true
+				order = append(order, ci)
+				columnShown[ci] = setting.Visible
+				if setting.Width >= COLUMN_MIN_WIDTH {
+					playlistCols[ci].SetFixedWidth(setting.Width)
+				}
+			}
+		}
+	}
+	for ci := range playlistColumns {
+		if !placed[ci] {
+			order = append(order, ci)
+			columnShown[ci] = false
+		}
+	}
+
+	// Chaining every column after the one before it puts the first first.
+	for i := 1; i < len(order); i++ {
+		playlistTree.MoveColumnAfter(playlistCols[order[i]], playlistCols[order[i-1]])
+	}
+	for ci := range playlistColumns {
+		playlistMenuColumns[ci].SetActive(columnShown[ci])
+		showColumn(ci)
+	}
+
+} // end SetColumnLayout
+
+// currentLayout reads the layout off the current playlist.
+func currentLayout() []ColumnSetting {
+
+	layout := make([]ColumnSetting, 0, len(playlistColumns))
+	for i := 0; i < len(playlistColumns); i++ {
+		title := playlistTree.GetColumn(i).GetTitle()
+		for ci, column := range playlistColumns {
+			if column.title == title {
+				width := playlistCols[ci].GetWidth()
+				if !playlistCols[ci].GetVisible() {
+					// A hidden column has no width of its own, but keeps
+					// the one it had.
+					width = playlistCols[ci].GetFixedWidth()
+				}
+				layout = append(layout, ColumnSetting{Name: column.name, Visible: columnShown[ci], Width: width})
+			}
+		}
+	}
+	return layout
+
+} // end currentLayout
+
+// ColumnLayout returns the layout of the current playlist as it was when
+// the window was closed.
+func ColumnLayout() []ColumnSetting {
+
+	return exitLayout
+
+} // end ColumnLayout
+
+// SetRatingsVisible shows or hides the rating column in the current playlist.
+func SetRatingsVisible(visible bool) {
+
+	ratingsAvailable = visible
+	showColumn(CUR_PL_COL_RATING - CUR_PL_COL_NAME)
+	playlistMenuColumns[CUR_PL_COL_RATING-CUR_PL_COL_NAME].SetSensitive(visible)
+
+} // end SetRatingsVisible
+
+// rowValues returns the values of a row of the current playlist, for its
+// model (the position is filled in by renumberRows).
+func rowValues(row *CurrentPLRow, pbuf interface{}) []interface{} {
+
+	duration := ""
+	if row.Duration >= 0 {
+		duration = formatDuration(row.Duration)
+	}
+	return []interface{}{row.ID, row.ArtworkPath, pbuf, row.File, row.Duration, !albumView,
+		strings.TrimSpace(row.Name + " " + row.Artist),
+		escapeHTML(row.Name), escapeHTML(row.Artist), escapeHTML(row.Album),
+		ratingStars(row.Rating), formatPriority(row.Priority),
+		escapeHTML(row.Track), escapeHTML(row.Disc), duration,
+		escapeHTML(row.Genre), escapeHTML(row.Date), escapeHTML(row.Composer),
+		escapeHTML(row.Performer), escapeHTML(row.AlbumArtist), escapeHTML(row.File),
+		"", ""}
+
+} // end rowValues
+
+// setRowText sets a text column of a row, in bold on the current song's.
+func setRowText(iter *gtk.TreeIter, ci int, text string) {
+
+	var id glib.GValue
+	playlistModel.GetValue(iter, CUR_PL_COL_ID, &id)
+	if id.GetInt() == currentBoldRow.ID && text != "" {
+		text = addBold(text)
+	}
+	playlistModel.SetValue(iter, ci, text)
+
+} // end setRowText
+
+// renumberRows fills in the position column after rows are added, removed
+// or moved.
+func renumberRows() {
+
+	var iter gtk.TreeIter
+	ok := playlistModel.GetIterFirst(&iter)
+	for pos := 1; ok; ok = playlistModel.IterNext(&iter) {
+		if !isHeader(&iter) {
+			setRowText(&iter, CUR_PL_COL_POSITION, strconv.Itoa(pos))
+			pos++
+		}
+	}
+
+} // end renumberRows
+
+// SetBitrate shows the bitrate of the current song (in kbps, 0 for none) on
+// its row.
+func SetBitrate(bitrate int) {
+
+	if bitrate == currentBitrate || currentBoldRow.gref == nil || !currentBoldRow.gref.Valid() {
+		return
+	}
+	currentBitrate = bitrate
+	var iter gtk.TreeIter
+	path := currentBoldRow.gref.GetPath()
+	defer path.Free()
+	playlistModel.GetIter(&iter, path)
+	text := ""
+	if bitrate > 0 {
+		text = strconv.Itoa(bitrate) + " kbps"
+	}
+	setRowText(&iter, CUR_PL_COL_BITRATE, text)
+
+} // end SetBitrate
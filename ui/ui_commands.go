@@ -0,0 +1,131 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has the commands: every action of Juke under a name,
+so that the keyboard shortcuts, the command palette and anything else that
+drives Juke by name share them. Each command is registered where its
+control is bound and runs that very control.
+*/
+
+package ui
+
+import (
+	"github.com/idealeric/juke/log"
+	"github.com/mattn/go-gtk/gtk"
+)
+
+// command is an action of Juke. run returns whether a key that ran it was
+// used up, if not the key goes on to the focused widget.
+type command struct {
+	name  string // as used in the configuration
+	title string // as shown to the user
+	keys  string // label of its shortcut, empty if it has none
+	run   func() bool
+}
+
+// Every command, in the order they were registered.
+var commands []*command
+
+// registerCommand adds a command. A command registered again replaces the
+// previous one.
+func registerCommand(name, title string, run func() bool) {
+
+	if existing := findCommand(name); existing != nil {
+		existing.title, existing.run = title, run
+		return
+	}
+	commands = append(commands, &command{name: name, title: title, run: run})
+
+} // end registerCommand
+
+// findCommand returns the command of a name, nil if there is none.
+func findCommand(name string) *command {
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+
+} // end findCommand
+
+// RunCommand runs a command by name, just as using its control would. It
+// returns whether there is such a command. Like any other call into the
+// UI from outside of it, it must be made with the UI locked.
+func RunCommand(name string) bool {
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		return false
+	}
+	cmd.run()
+	return true
+
+} // end RunCommand
+
+// pressButton presses a playback button, if it can be.
+func pressButton(button uint8) bool {
+
+	if playBackControls[button].GetSensitive() {
+		playBackControls[button].Released()
+	}
+	return true
+
+} // end pressButton
+
+// activateItem activates a menu item, if it can be.
+func activateItem(item *gtk.MenuItem) bool {
+
+	if item.GetSensitive() {
+		item.Activate()
+	}
+	return true
+
+} // end activateItem
+
+// activatePlaylistItem activates an item of the current playlist menu, if
+// the current playlist can be used.
+func activatePlaylistItem(item *gtk.MenuItem) bool {
+
+	if playlistTree.GetSensitive() {
+		activateItem(item)
+	}
+	return true
+
+} // end activatePlaylistItem
+
+// activateServerItem activates an item of the server menu, if the
+// connection button (which pops the menu up) can be used.
+func activateServerItem(item *gtk.MenuItem) bool {
+
+	if rightControls[CONNECTION_BUTTON].GetSensitive() {
+		activateItem(item)
+	}
+	return true
+
+} // end activateServerItem
+
+// seekBy seeks the current song by some seconds.
+func seekBy(seconds int) bool {
+
+	// Streams (of no duration) can not be seeked.
+	if keySeek != nil && progressBarEvent.GetSensitive() && progressDuration > 0 {
+		if err := keySeek(seconds); err != nil {
+			log.ErrorReport("UI seekBy()", err.Error()+".")
+		}
+	}
+	return true
+
+} // end seekBy
+
+// changeVolume moves the volume slider (which sets the volume) by some
+// percent.
+func changeVolume(change float64) bool {
+
+	if rightControls[VOLUME_BUTTON].GetSensitive() && volumeScale.GetSensitive() {
+		volumeScale.SetValue(volumeScale.GetValue() + change)
+	}
+	return true
+
+} // end changeVolume
@@ -0,0 +1,46 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has Juke's small, one-off dialogs.
+*/
+
+package ui
+
+import (
+	"github.com/mattn/go-gtk/gtk"
+)
+
+// Scrobble export formats:
+type ScrobbleFormat uint8
+
+const (
+	SCROBBLE_ROCKBOX ScrobbleFormat = iota
+	SCROBBLE_LISTENBRAINZ
+)
+
+// ShowMessage displays an informational message to the user. It returns
+// at once, the message going away when the user closes it: it is shown
+// from update() (under the UI lock), which must not wait on the user.
+func ShowMessage(message string) {
+
+	dialog := gtk.NewMessageDialog(window, gtk.DIALOG_DESTROY_WITH_PARENT, gtk.MESSAGE_INFO, gtk.BUTTONS_OK, "%s", message)
+	dialog.Connect("response", dialog.Destroy)
+	dialog.ShowAll()
+
+} // end ShowMessage
+
+// chooseSaveFilename asks the user where to save a file. An empty
+// string is returned if the user cancels.
+func chooseSaveFilename(title, suggested string) string {
+
+	dialog := gtk.NewFileChooserDialog(title, window, gtk.FILE_CHOOSER_ACTION_SAVE,
+		gtk.STOCK_CANCEL, gtk.RESPONSE_CANCEL, gtk.STOCK_SAVE, gtk.RESPONSE_ACCEPT)
+	dialog.SetCurrentName(suggested)
+	filename := ""
+	if dialog.Run() == gtk.RESPONSE_ACCEPT {
+		filename = dialog.GetFilename()
+	}
+	dialog.Destroy()
+	return filename
+
+} // end chooseSaveFilename
@@ -7,8 +7,7 @@ This particular file has all of Juke's ui helper functions.
 package ui
 
 import (
-	"github.com/mattn/go-gtk/glib"
-	"github.com/mattn/go-gtk/gtk"
+	"strconv"
 	"strings"
 )
 
@@ -38,24 +37,44 @@ func removeBold(str string) string {
 
 } // end removeBold
 
-// makeSortFunc creates a sort function for the specified column number.
-func makeSortFunc(col int) func(*gtk.TreeModel, *gtk.TreeIter, *gtk.TreeIter) int {
-
-	return func(m *gtk.TreeModel, a *gtk.TreeIter, b *gtk.TreeIter) int {
-		var (
-			vala glib.GValue
-			valb glib.GValue
-		)
-		playlistModel.GetValue(a, col, &vala)
-		playlistModel.GetValue(b, col, &valb)
-		stra, strb := removeBold(vala.GetString()), removeBold(valb.GetString())
-		if stra == strb {
-			return 0
-		} else if stra > strb {
-			return 1
-		} else {
-			return -1
+// formatDuration turns a number of seconds into h:mm:ss (or m:ss when
+// there are no hours).
+func formatDuration(seconds int) string {
+
+	hours, minutes, secs := seconds/3600, (seconds/60)%60, seconds%60
+	ret := ""
+	if hours > 0 {
+		ret = strconv.Itoa(hours) + ":"
+		if minutes < 10 {
+			ret += "0"
 		}
 	}
+	ret += strconv.Itoa(minutes) + ":"
+	if secs < 10 {
+		ret += "0"
+	}
+	return ret + strconv.Itoa(secs)
+
+} // end formatDuration
+
+// ratingStars draws a rating as a row of filled and empty stars.
+func ratingStars(rating int) string {
+
+	if rating < 0 {
+		rating = 0
+	} else if rating > CUR_PL_MAX_RATING {
+		rating = CUR_PL_MAX_RATING
+	}
+	return strings.Repeat("★", rating) + strings.Repeat("☆", CUR_PL_MAX_RATING-rating)
+
+} // end ratingStars
+
+// formatPriority shows a queue priority (nothing for none).
+func formatPriority(priority int) string {
+
+	if priority <= 0 {
+		return ""
+	}
+	return strconv.Itoa(priority)
 
-} // end makeSortFunc
+} // end formatPriority
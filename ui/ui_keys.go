@@ -0,0 +1,232 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has the keyboard shortcuts of the main window (which
+run commands) and the window listing them.
+*/
+
+package ui
+
+import (
+	"github.com/idealeric/juke/log"
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/glib"
+	"github.com/mattn/go-gtk/gtk"
+	"strings"
+	"unsafe"
+)
+
+// How far (in seconds) the seek shortcuts seek, and how much (in percent)
+// the volume shortcuts change the volume.
+const (
+	SEEK_STEP   = 5
+	VOLUME_STEP = 5
+)
+
+const (
+	SHORTCUT_COL_COMMAND int = iota
+	SHORTCUT_COL_KEYS
+)
+
+// The modifiers a shortcut can use, the others (caps lock, ...) are ignored.
+const SHORTCUT_MODIFIERS = uint(gdk.CONTROL_MASK | gdk.SHIFT_MASK | gdk.MOD1_MASK)
+
+// shortcut is a key with its modifiers.
+type shortcut struct {
+	keyval    uint
+	modifiers uint
+}
+
+// Names of the keys that are not a single letter, digit or sign.
+var keyNames = map[string]uint{
+	"space": 0x020, "Tab": 0xff09, "Return": 0xff0d, "Escape": 0xff1b,
+	"BackSpace": 0xff08, "Delete": 0xffff, "Insert": 0xff63,
+	"Home": 0xff50, "End": 0xff57, "Page_Up": 0xff55, "Page_Down": 0xff56,
+	"Left": 0xff51, "Up": 0xff52, "Right": 0xff53, "Down": 0xff54,
+	"plus": 0x02b, "minus": 0x02d, "equal": 0x03d, "comma": 0x02c,
+	"period": 0x02e, "slash": 0x02f, "question": 0x03f,
+	"F1": 0xffbe, "F2": 0xffbf, "F3": 0xffc0, "F4": 0xffc1, "F5": 0xffc2,
+	"F6": 0xffc3, "F7": 0xffc4, "F8": 0xffc5, "F9": 0xffc6, "F10": 0xffc7,
+	"F11": 0xffc8, "F12": 0xffc9}
+
+// Global referances for the keyboard shortcuts.
+var (
+	keyBindings       map[shortcut]*command // The active shortcuts
+	keySeek           func(int) error       // Seeks by some seconds (bound by KeySeek)
+	undoAvailable     bool                  // Whether there is an edit to undo
+	redoAvailable     bool                  // Whether there is an undo to redo
+	shortcutsWindow   *gtk.Window           // Keyboard shortcuts window
+	shortcutsModel    *gtk.ListStore        // Model of the active shortcuts
+	mainMenuShortcuts *gtk.MenuItem         // Main menu item for the keyboard shortcuts window
+)
+
+// parseShortcut reads a key written as a GTK accelerator, such as
+// "<Control><Shift>z".
+func parseShortcut(accelerator string) (shortcut, bool) {
+
+	var key shortcut
+	for strings.HasPrefix(accelerator, "<") {
+		end := strings.Index(accelerator, ">")
+		if end < 0 {
+			return key, false
+		}
+		switch strings.ToLower(accelerator[1:end]) {
+		case "control", "ctrl", "primary":
+			key.modifiers |= uint(gdk.CONTROL_MASK)
+		case "shift":
+			key.modifiers |= uint(gdk.SHIFT_MASK)
+		case "alt", "mod1":
+			key.modifiers |= uint(gdk.MOD1_MASK)
+		default:
+			return key, false
+		}
+		accelerator = accelerator[end+1:]
+	}
+
+	if keyval, exists := keyNames[accelerator]; exists {
+		key.keyval = keyval
+	} else if len(accelerator) == 1 && accelerator[0] > 0x20 && accelerator[0] < 0x7f {
+		key.keyval = uint(accelerator[0])
+	} else {
+		return key, false
+	}
+	return key.normalized(), true
+
+} // end parseShortcut
+
+// shortcutLabel writes a key the way it is shown, such as "Ctrl+Shift+Z".
+func shortcutLabel(key shortcut) string {
+
+	label := ""
+	if key.modifiers&uint(gdk.CONTROL_MASK) != 0 {
+		label += "Ctrl+"
+	}
+	if key.modifiers&uint(gdk.SHIFT_MASK) != 0 {
+		label += "Shift+"
+	}
+	if key.modifiers&uint(gdk.MOD1_MASK) != 0 {
+		label += "Alt+"
+	}
+	for name, keyval := range keyNames {
+		if keyval == key.keyval {
+			name = strings.Replace(name, "_", " ", -1)
+			return label + strings.ToUpper(name[:1]) + name[1:]
+		}
+	}
+	return label + strings.ToUpper(string(rune(key.keyval)))
+
+} // end shortcutLabel
+
+// normalized returns the shortcut the way it is matched: letters in lower
+// case (shift being a modifier) and signs without shift.
+func (key shortcut) normalized() shortcut {
+
+	if key.keyval >= 'A' && key.keyval <= 'Z' {
+		key.keyval += 'a' - 'A'
+	} else if key.keyval > 0x20 && key.keyval < 0x7f && (key.keyval < 'a' || key.keyval > 'z') {
+		// Signs already depend on shift ("?" is a shifted "/"), so it is not
+		// part of their shortcut.
+		key.modifiers &^= uint(gdk.SHIFT_MASK)
+	}
+	return key
+
+} // end normalized
+
+// SetBindings sets the keyboard shortcuts, from command names to keys
+// written as GTK accelerators. Unknown commands and keys are reported and
+// left out, an empty key leaves the command without a shortcut. The
+// commands are only all known once the callbacks are bound.
+func SetBindings(bindings map[string]string) {
+
+	keyBindings = make(map[shortcut]*command)
+	for name := range bindings {
+		if findCommand(name) == nil {
+			log.ErrorReport("UI SetBindings()", "There is no command called "+name+" to bind.")
+		}
+	}
+
+	shortcutsModel.Clear()
+	for _, cmd := range commands {
+		accelerator := bindings[cmd.name]
+		cmd.keys = ""
+		if accelerator != "" {
+			key, valid := parseShortcut(accelerator)
+			if !valid {
+				log.ErrorReport("UI SetBindings()", "Could not read the key "+accelerator+" bound to "+cmd.name+".")
+			} else if other, taken := keyBindings[key]; taken {
+				log.ErrorReport("UI SetBindings()", "The key "+accelerator+" is bound to both "+other.name+" and "+cmd.name+".")
+			} else {
+				keyBindings[key] = cmd
+				cmd.keys = shortcutLabel(key)
+			}
+		}
+		if cmd.keys != "" {
+			var iter gtk.TreeIter
+			shortcutsModel.Append(&iter)
+			shortcutsModel.Set(&iter, cmd.title, cmd.keys)
+		}
+	}
+
+} // end SetBindings
+
+// initShortcutsWindow builds the (initially hidden) keyboard shortcuts
+// window and listens for the shortcuts on the main window.
+func initShortcutsWindow() {
+
+	registerCommand("find", "Find a song in the playlist", func() bool {
+		// The playlist's own search takes the key once it has the focus.
+		playlistTree.GrabFocus()
+		return false
+	})
+	registerCommand("shortcuts", "Show the keyboard shortcuts", func() bool {
+		ShowShortcutsWindow()
+		return true
+	})
+
+	shortcutsWindow = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	shortcutsWindow.SetTransientFor(window)
+	shortcutsWindow.SetPosition(gtk.WIN_POS_CENTER)
+	shortcutsWindow.SetTitle("Keyboard Shortcuts [Juke]")
+	shortcutsWindow.SetDefaultSize(360, 400)
+	shortcutsWindow.SetBorderWidth(8)
+	// Closing only hides the window, it is reused on the next opening.
+	shortcutsWindow.Connect("delete-event", func() bool {
+		shortcutsWindow.Hide()
+		return true
+	})
+
+	shortcutsBox := gtk.NewVBox(false, 8)
+	shortcutsModel = gtk.NewListStore(gtk.TYPE_STRING, gtk.TYPE_STRING)
+	shortcutsTree := gtk.NewTreeView()
+	shortcutsTree.SetModel(shortcutsModel)
+	shortcutsTree.AppendColumn(gtk.NewTreeViewColumnWithAttributes("Command", gtk.NewCellRendererText(), "text", SHORTCUT_COL_COMMAND))
+	shortcutsTree.AppendColumn(gtk.NewTreeViewColumnWithAttributes("Keys", gtk.NewCellRendererText(), "text", SHORTCUT_COL_KEYS))
+	shortcutsScroll := gtk.NewScrolledWindow(nil, nil)
+	shortcutsScroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	shortcutsScroll.Add(shortcutsTree)
+	shortcutsBox.PackStart(shortcutsScroll, true, true, 0)
+	shortcutsBox.PackStart(gtk.NewLabel("Shortcuts are set in the bindings section of the configuration."), false, false, 0)
+	shortcutsWindow.Add(shortcutsBox)
+
+	mainMenuShortcuts.Connect("activate", ShowShortcutsWindow)
+
+	// The main window sees every key before the focused widget does.
+	window.Connect("key-press-event", func(cntx *glib.CallbackContext) bool {
+		arg := cntx.Args(0)
+		eventKey := *(**gdk.EventKey)(unsafe.Pointer(&arg))
+		pressed := shortcut{keyval: uint(eventKey.Keyval), modifiers: uint(eventKey.State) & SHORTCUT_MODIFIERS}
+		if cmd, bound := keyBindings[pressed.normalized()]; bound {
+			return cmd.run()
+		}
+		return false
+	})
+
+} // end initShortcutsWindow
+
+// ShowShortcutsWindow brings the keyboard shortcuts window to the front.
+func ShowShortcutsWindow() {
+
+	shortcutsWindow.ShowAll()
+	shortcutsWindow.Present()
+
+} // end ShowShortcutsWindow
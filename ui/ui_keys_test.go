@@ -0,0 +1,61 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file tests the reading of keyboard shortcuts.
+*/
+
+package ui
+
+import (
+	"github.com/mattn/go-gtk/gdk"
+	"testing"
+)
+
+func TestParseShortcut(t *testing.T) {
+
+	const (
+		ctrl  = uint(gdk.CONTROL_MASK)
+		shift = uint(gdk.SHIFT_MASK)
+		alt   = uint(gdk.MOD1_MASK)
+	)
+
+	tests := []struct {
+		accelerator string
+		want        shortcut
+		ok          bool
+	}{
+		{"z", shortcut{'z', 0}, true},
+		{"Z", shortcut{'z', 0}, true},
+		{"<Control>z", shortcut{'z', ctrl}, true},
+		{"<Ctrl>z", shortcut{'z', ctrl}, true},
+		{"<Primary>z", shortcut{'z', ctrl}, true},
+		{"<control>z", shortcut{'z', ctrl}, true},
+		{"<Control><Shift>z", shortcut{'z', ctrl | shift}, true},
+		{"<Shift><Control>Z", shortcut{'z', ctrl | shift}, true},
+		{"<Alt>Left", shortcut{0xff51, alt}, true},
+		{"<Mod1>Left", shortcut{0xff51, alt}, true},
+		{"<Control><Alt><Shift>F5", shortcut{0xffc2, ctrl | alt | shift}, true},
+		{"<Shift>Page_Down", shortcut{0xff56, shift}, true},
+		{"space", shortcut{0x020, 0}, true},
+		{"<Control>plus", shortcut{'+', ctrl}, true},
+		{"?", shortcut{'?', 0}, true},
+		{"<Shift>?", shortcut{'?', 0}, true}, // shift is part of the sign
+		{"<Shift>slash", shortcut{'/', 0}, true},
+		{"1", shortcut{'1', 0}, true},
+		{"", shortcut{}, false},
+		{"<Control>", shortcut{}, false},
+		{"<Super>z", shortcut{}, false},
+		{"<Control z", shortcut{}, false},
+		{"zz", shortcut{}, false},
+		{"F13", shortcut{}, false},
+		{"é", shortcut{}, false},
+	}
+
+	for _, test := range tests {
+		got, ok := parseShortcut(test.accelerator)
+		if ok != test.ok || (ok && got != test.want) {
+			t.Errorf("parseShortcut(%q) = %+v, %v, want %+v, %v", test.accelerator, got, ok, test.want, test.ok)
+		}
+	}
+
+} // end TestParseShortcut
@@ -0,0 +1,171 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has the audio outputs window.
+*/
+
+package ui
+
+import (
+	"github.com/mattn/go-gtk/glib"
+	"github.com/mattn/go-gtk/gtk"
+	"strings"
+)
+
+// What can be done to an output:
+type OutputAction uint8
+
+const (
+	OUTPUT_ENABLE OutputAction = iota
+	OUTPUT_DISABLE
+	OUTPUT_TOGGLE
+)
+
+const (
+	OUTPUT_COL_ID int = iota
+	OUTPUT_COL_NAME
+	OUTPUT_COL_PLUGIN
+	OUTPUT_COL_STATE
+	OUTPUT_COL_ATTRIBUTES
+)
+
+// OutputRow is a single audio output of the server.
+type OutputRow struct {
+	ID         int
+	Name       string
+	Plugin     string
+	Enabled    bool
+	Attributes []string // as name=value
+}
+
+// Global referances for the outputs window.
+var (
+	outputsWindow    *gtk.Window        // Audio outputs window
+	outputsModel     *gtk.ListStore     // Model of the outputs
+	outputsSelection *gtk.TreeSelection // Selected output
+	outputsButtons   [3]*gtk.Button     // Enable, disable and toggle buttons (by OutputAction)
+	outputsMoveBox   *gtk.HBox          // Partition controls (hidden without partitions)
+	outputsPartition *gtk.ComboBoxText  // Partition to move the selected output to
+	outputsMove      *gtk.Button        // Moves the selected output
+	mainMenuOutputs  *gtk.MenuItem      // Main menu item for the outputs window
+)
+
+// initOutputsWindow builds the (initially hidden) audio outputs window.
+func initOutputsWindow() {
+
+	outputsWindow = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	outputsWindow.SetTransientFor(window)
+	outputsWindow.SetPosition(gtk.WIN_POS_CENTER)
+	outputsWindow.SetTitle("Audio Outputs [Juke]")
+	outputsWindow.SetDefaultSize(560, 260)
+	outputsWindow.SetBorderWidth(8)
+	// Closing only hides the window, it is reused on the next opening.
+	outputsWindow.Connect("delete-event", func() bool {
+		outputsWindow.Hide()
+		return true
+	})
+
+	outputsBox := gtk.NewVBox(false, 8)
+
+	outputsModel = gtk.NewListStore(gtk.TYPE_INT, gtk.TYPE_STRING, gtk.TYPE_STRING, gtk.TYPE_STRING, gtk.TYPE_STRING)
+	outputsTree := gtk.NewTreeView()
+	outputsTree.SetModel(outputsModel)
+	outputColNames := []string{"ID", "Output", "Plugin", "State", "Attributes"}
+	for ci := OUTPUT_COL_NAME; ci <= OUTPUT_COL_ATTRIBUTES; ci++ {
+		outputCol := gtk.NewTreeViewColumnWithAttributes(outputColNames[ci], gtk.NewCellRendererText(), "text", ci)
+		outputCol.SetResizable(true)
+		outputsTree.AppendColumn(outputCol)
+	}
+	outputsSelection = outputsTree.GetSelection()
+	outputsSelection.SetMode(gtk.SELECTION_SINGLE)
+	outputsScroll := gtk.NewScrolledWindow(nil, nil)
+	outputsScroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	outputsScroll.Add(outputsTree)
+	outputsBox.PackStart(outputsScroll, true, true, 0)
+
+	buttonBox := gtk.NewHBox(false, 8)
+	for i, label := range []string{"Enable", "Disable", "Toggle"} {
+		outputsButtons[i] = gtk.NewButtonWithLabel(label)
+		buttonBox.PackStart(outputsButtons[i], false, false, 0)
+	}
+	outputsMoveBox = gtk.NewHBox(false, 8)
+	outputsPartition = gtk.NewComboBoxText()
+	outputsMove = gtk.NewButtonWithLabel("Move to Partition")
+	outputsMoveBox.PackStart(outputsMove, false, false, 0)
+	outputsMoveBox.PackStart(outputsPartition, false, false, 0)
+	outputsMoveBox.SetNoShowAll(true)
+	buttonBox.PackEnd(outputsMoveBox, false, false, 0)
+	outputsBox.PackStart(buttonBox, false, false, 0)
+
+	outputsWindow.Add(outputsBox)
+
+	mainMenuOutputs.Connect("activate", func() {
+		outputsWindow.ShowAll()
+		outputsWindow.Present()
+	})
+
+} // end initOutputsWindow
+
+// SetOutputs replaces the outputs shown, keeping the selection.
+func SetOutputs(rows []*OutputRow) {
+
+	selected, wasSelected := selectedOutput()
+
+	outputsModel.Clear()
+	for _, row := range rows {
+		state := "Off"
+		if row.Enabled {
+			state = "On"
+		}
+		var iter gtk.TreeIter
+		outputsModel.Append(&iter)
+		outputsModel.Set(&iter, row.ID, row.Name, row.Plugin, state, strings.Join(row.Attributes, ", "))
+		if wasSelected && row.ID == selected {
+			outputsSelection.SelectIter(&iter)
+		}
+	}
+
+} // end SetOutputs
+
+// SetOutputPartitions sets the partitions outputs may be moved to. Without
+// any (the server does not support them) the controls are hidden.
+func SetOutputPartitions(partitions []string) {
+
+	outputsPartition.RemoveAll()
+	for _, partition := range partitions {
+		outputsPartition.AppendText(partition)
+	}
+	if len(partitions) == 0 {
+		outputsMoveBox.Hide()
+	} else {
+		outputsPartition.SetActive(0)
+		outputsMoveBox.ShowAll()
+	}
+
+} // end SetOutputPartitions
+
+// selectedOutput returns the ID of the selected output, if any.
+func selectedOutput() (int, bool) {
+
+	var iter gtk.TreeIter
+	if !outputsSelection.GetSelected(&iter) {
+		return 0, false
+	}
+	var id glib.GValue
+	outputsModel.GetValue(&iter, OUTPUT_COL_ID, &id)
+	return id.GetInt(), true
+
+} // end selectedOutput
+
+// selectedOutputName returns the name of the selected output, if any.
+func selectedOutputName() (string, bool) {
+
+	var iter gtk.TreeIter
+	if !outputsSelection.GetSelected(&iter) {
+		return "", false
+	}
+	var name glib.GValue
+	outputsModel.GetValue(&iter, OUTPUT_COL_NAME, &name)
+	return name.GetString(), true
+
+} // end selectedOutputName
@@ -0,0 +1,283 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has the command palette: a window that finds any
+command, or any song of the library, from a few letters of its name.
+*/
+
+package ui
+
+import (
+	"github.com/idealeric/juke/log"
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/glib"
+	"github.com/mattn/go-gtk/gtk"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unsafe"
+)
+
+const (
+	PALETTE_COL_INDEX int = iota
+	PALETTE_COL_TITLE
+	PALETTE_COL_KEYS
+)
+
+// The shortest query the library is searched for.
+const PALETTE_MIN_SEARCH = 3
+
+// PaletteSong is a song of the library found for the command palette.
+type PaletteSong struct {
+	File   string
+	Title  string
+	Artist string
+	Album  string
+}
+
+// paletteEntry is a line of the command palette.
+type paletteEntry struct {
+	title string
+	keys  string
+	score int
+	run   func()
+}
+
+// Global referances for the command palette.
+var (
+	paletteWindow    *gtk.Window        // Command palette window
+	paletteQuery     *gtk.Entry         // What is looked for
+	paletteTree      *gtk.TreeView      // Treeview of what was found
+	paletteModel     *gtk.ListStore     // Model of what was found
+	paletteSelection *gtk.TreeSelection // The line to run
+	paletteEntries   []*paletteEntry    // What was found, by line
+	paletteSongs     []PaletteSong      // Songs of the library found for the query
+	paletteSearch    func(string) error // Searches the library (bound by PaletteSearch)
+	paletteAdd       func(string) error // Adds a song of the library (bound by PaletteAddSong)
+	mainMenuPalette  *gtk.MenuItem      // Main menu item for the command palette
+)
+
+// fuzzyScore tells whether the letters of a query appear, in order, in a
+// text and how well they do: letters that follow each other or start words
+// count for more.
+func fuzzyScore(query, text string) (int, bool) {
+
+	letters := []rune(strings.ToLower(text))
+	score, at, last := 0, 0, -2
+	for _, wanted := range strings.ToLower(query) {
+		if unicode.IsSpace(wanted) {
+			continue
+		}
+		for at < len(letters) && letters[at] != wanted {
+			at++
+		}
+		if at == len(letters) {
+			return 0, false
+		}
+		score++
+		if at == last+1 {
+			score += 2
+		}
+		if at == 0 || !unicode.IsLetter(letters[at-1]) {
+			score += 3
+		}
+		last = at
+		at++
+	}
+	return score, true
+
+} // end fuzzyScore
+
+// songTitle is how a song of the library is shown in the palette.
+func songTitle(song PaletteSong) string {
+
+	title := song.Title
+	if title == "" {
+		title = song.File
+	}
+	if song.Artist != "" {
+		title += " - " + song.Artist
+	}
+	if song.Album != "" {
+		title += " (" + song.Album + ")"
+	}
+	return "Add song: " + title
+
+} // end songTitle
+
+// fillPalette lists the commands matching the query, best first, and then
+// the songs found for it.
+func fillPalette() {
+
+	selected := selectedPaletteEntry()
+	query := paletteQuery.GetText()
+	paletteEntries = nil
+	for _, cmd := range commands {
+		if score, matches := fuzzyScore(query, cmd.title); matches {
+			run := cmd.run
+			paletteEntries = append(paletteEntries, &paletteEntry{title: cmd.title, keys: cmd.keys, score: score, run: func() { run() }})
+		}
+	}
+	sort.SliceStable(paletteEntries, func(i, j int) bool {
+		return paletteEntries[i].score > paletteEntries[j].score
+	})
+	for _, song := range paletteSongs {
+		file := song.File
+		paletteEntries = append(paletteEntries, &paletteEntry{title: songTitle(song), run: func() {
+			// Songs are only added while the playlist can be used.
+			if paletteAdd != nil && playlistTree.GetSensitive() {
+				if err := paletteAdd(file); err != nil {
+					log.ErrorReport("UI fillPalette()", err.Error()+".")
+				}
+			}
+		}})
+	}
+
+	paletteModel.Clear()
+	for i, entry := range paletteEntries {
+		var iter gtk.TreeIter
+		paletteModel.Append(&iter)
+		paletteModel.Set(&iter, i, entry.title, entry.keys)
+	}
+	if selected < 0 || selected >= len(paletteEntries) {
+		selected = 0
+	}
+	selectPaletteEntry(selected)
+
+} // end fillPalette
+
+// selectedPaletteEntry returns the line selected in the palette, -1 if none.
+func selectedPaletteEntry() int {
+
+	var iter gtk.TreeIter
+	if !paletteSelection.GetSelected(&iter) {
+		return -1
+	}
+	var index glib.GValue
+	paletteModel.GetValue(&iter, PALETTE_COL_INDEX, &index)
+	return index.GetInt()
+
+} // end selectedPaletteEntry
+
+// selectPaletteEntry selects a line of the palette (if there is one).
+func selectPaletteEntry(index int) {
+
+	if index < 0 || index >= len(paletteEntries) {
+		return
+	}
+	path := gtk.NewTreePathFromString(strconv.Itoa(index))
+	paletteSelection.SelectPath(path)
+	paletteTree.ScrollToCell(path, nil, false, 0, 0)
+
+} // end selectPaletteEntry
+
+// runPaletteEntry closes the palette and runs the selected line.
+func runPaletteEntry() {
+
+	index := selectedPaletteEntry()
+	if index < 0 {
+		return
+	}
+	paletteWindow.Hide()
+	paletteEntries[index].run()
+
+} // end runPaletteEntry
+
+// initPaletteWindow builds the (initially hidden) command palette.
+func initPaletteWindow() {
+
+	paletteWindow = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	paletteWindow.SetTransientFor(window)
+	paletteWindow.SetPosition(gtk.WIN_POS_CENTER)
+	paletteWindow.SetTitle("Command Palette [Juke]")
+	paletteWindow.SetDefaultSize(520, 360)
+	paletteWindow.SetBorderWidth(8)
+	// Closing only hides the window, it is reused on the next opening.
+	paletteWindow.Connect("delete-event", func() bool {
+		paletteWindow.Hide()
+		return true
+	})
+
+	paletteBox := gtk.NewVBox(false, 8)
+	paletteQuery = gtk.NewEntry()
+	paletteBox.PackStart(paletteQuery, false, false, 0)
+	paletteModel = gtk.NewListStore(gtk.TYPE_INT, gtk.TYPE_STRING, gtk.TYPE_STRING)
+	paletteTree = gtk.NewTreeView()
+	paletteTree.SetModel(paletteModel)
+	paletteTree.SetHeadersVisible(false)
+	titleCol := gtk.NewTreeViewColumnWithAttributes("Command", gtk.NewCellRendererText(), "text", PALETTE_COL_TITLE)
+	titleCol.SetExpand(true)
+	paletteTree.AppendColumn(titleCol)
+	paletteTree.AppendColumn(gtk.NewTreeViewColumnWithAttributes("Keys", gtk.NewCellRendererText(), "text", PALETTE_COL_KEYS))
+	paletteSelection = paletteTree.GetSelection()
+	paletteSelection.SetMode(gtk.SELECTION_SINGLE)
+	paletteScroll := gtk.NewScrolledWindow(nil, nil)
+	paletteScroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	paletteScroll.Add(paletteTree)
+	paletteBox.PackStart(paletteScroll, true, true, 0)
+	paletteWindow.Add(paletteBox)
+
+	paletteQuery.Connect("changed", func() {
+		query := strings.TrimSpace(paletteQuery.GetText())
+		paletteSongs = nil
+		if len(query) >= PALETTE_MIN_SEARCH && paletteSearch != nil {
+			// The songs are added to the list once they are found.
+			if err := paletteSearch(query); err != nil {
+				log.ErrorReport("UI initPaletteWindow()", err.Error()+".")
+			}
+		}
+		fillPalette()
+	})
+	paletteQuery.Connect("activate", runPaletteEntry)
+	// The selection is moved from the query, which keeps the focus.
+	paletteQuery.Connect("key-press-event", func(cntx *glib.CallbackContext) bool {
+		arg := cntx.Args(0)
+		eventKey := *(**gdk.EventKey)(unsafe.Pointer(&arg))
+		switch uint(eventKey.Keyval) {
+		case keyNames["Up"]:
+			selectPaletteEntry(selectedPaletteEntry() - 1)
+			return true
+		case keyNames["Down"]:
+			selectPaletteEntry(selectedPaletteEntry() + 1)
+			return true
+		case keyNames["Escape"]:
+			paletteWindow.Hide()
+			return true
+		}
+		return false
+	})
+	paletteTree.Connect("row-activated", runPaletteEntry)
+
+	mainMenuPalette.Connect("activate", ShowPaletteWindow)
+	registerCommand("palette", "Show the command palette", func() bool {
+		ShowPaletteWindow()
+		return true
+	})
+
+} // end initPaletteWindow
+
+// ShowPaletteWindow brings the command palette to the front, ready for a
+// new query.
+func ShowPaletteWindow() {
+
+	paletteSongs = nil
+	paletteQuery.SetText("")
+	fillPalette()
+	paletteWindow.ShowAll()
+	paletteWindow.Present()
+	paletteQuery.GrabFocus()
+
+} // end ShowPaletteWindow
+
+// SetPaletteSongs lists the songs of the library found for a query, unless
+// the query has changed since.
+func SetPaletteSongs(query string, songs []PaletteSong) {
+
+	if query != strings.TrimSpace(paletteQuery.GetText()) {
+		return
+	}
+	paletteSongs = songs
+	fillPalette()
+
+} // end SetPaletteSongs
@@ -0,0 +1,47 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file tests the matching of the command palette.
+*/
+
+package ui
+
+import (
+	"testing"
+)
+
+func TestFuzzyScore(t *testing.T) {
+
+	tests := []struct {
+		query, text string
+		score       int
+		ok          bool
+	}{
+		{"", "Play", 0, true},
+		{"abc", "abc", 10, true},      // a word start, then two following letters
+		{"ABC", "abc", 10, true},      // case is ignored
+		{"ac", "abc", 5, true},        // c neither follows a nor starts a word
+		{"pp", "Play Pause", 8, true}, // both start words
+		{"a b", "ab", 7, true},        // spaces in the query are ignored
+		{"ab", "xab", 4, true},        // inside a word
+		{"ba", "abc", 0, false},       // out of order
+		{"x", "abc", 0, false},
+		{"abcd", "abc", 0, false},
+		{"s", "", 0, false},
+	}
+
+	for _, test := range tests {
+		score, ok := fuzzyScore(test.query, test.text)
+		if ok != test.ok || score != test.score {
+			t.Errorf("fuzzyScore(%q, %q) = %d, %v, want %d, %v", test.query, test.text, score, ok, test.score, test.ok)
+		}
+	}
+
+	// Word starts and runs rank a text above one merely containing the letters.
+	better, _ := fuzzyScore("sh", "Shuffle")
+	worse, _ := fuzzyScore("sh", "Smart: Rush")
+	if better <= worse {
+		t.Errorf("fuzzyScore ranks Shuffle (%d) no higher than Smart: Rush (%d)", better, worse)
+	}
+
+} // end TestFuzzyScore
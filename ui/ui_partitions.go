@@ -0,0 +1,136 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has the partitions window.
+*/
+
+package ui
+
+import (
+	"github.com/mattn/go-gtk/glib"
+	"github.com/mattn/go-gtk/gtk"
+)
+
+// The partition MPD starts clients in.
+const DEFAULT_PARTITION = "default"
+
+const (
+	PARTITION_COL_NAME int = iota
+	PARTITION_COL_CURRENT
+)
+
+// Global referances for the partitions window.
+var (
+	partitionsWindow    *gtk.Window        // Partitions window
+	partitionsModel     *gtk.ListStore     // Model of the partitions
+	partitionsSelection *gtk.TreeSelection // Selected partition
+	partitionsSwitch    *gtk.Button        // Switches to the selected partition
+	partitionsDelete    *gtk.Button        // Deletes the selected partition
+	partitionsName      *gtk.Entry         // Name of a new partition
+	partitionsNew       *gtk.Button        // Creates a new partition
+	serverMenuPartition *gtk.MenuItem      // Server menu item for the partitions window
+	windowSubject       string             // Window title, less Juke's name and the partition
+	windowPartition     string             // Partition shown in the window title
+)
+
+// initPartitionsWindow builds the (initially hidden) partitions window.
+func initPartitionsWindow() {
+
+	partitionsWindow = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	partitionsWindow.SetTransientFor(window)
+	partitionsWindow.SetPosition(gtk.WIN_POS_CENTER)
+	partitionsWindow.SetTitle("Partitions [Juke]")
+	partitionsWindow.SetDefaultSize(320, 260)
+	partitionsWindow.SetBorderWidth(8)
+	// Closing only hides the window, it is reused on the next opening.
+	partitionsWindow.Connect("delete-event", func() bool {
+		partitionsWindow.Hide()
+		return true
+	})
+
+	partitionsBox := gtk.NewVBox(false, 8)
+
+	partitionsModel = gtk.NewListStore(gtk.TYPE_STRING, gtk.TYPE_STRING)
+	partitionsTree := gtk.NewTreeView()
+	partitionsTree.SetModel(partitionsModel)
+	partitionsTree.AppendColumn(gtk.NewTreeViewColumnWithAttributes("Partition", gtk.NewCellRendererText(), "text", PARTITION_COL_NAME))
+	partitionsTree.AppendColumn(gtk.NewTreeViewColumnWithAttributes("", gtk.NewCellRendererText(), "text", PARTITION_COL_CURRENT))
+	partitionsSelection = partitionsTree.GetSelection()
+	partitionsSelection.SetMode(gtk.SELECTION_SINGLE)
+	partitionsScroll := gtk.NewScrolledWindow(nil, nil)
+	partitionsScroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	partitionsScroll.Add(partitionsTree)
+	partitionsBox.PackStart(partitionsScroll, true, true, 0)
+
+	selectedBox := gtk.NewHBox(false, 8)
+	partitionsSwitch = gtk.NewButtonWithLabel("Switch To")
+	selectedBox.PackStart(partitionsSwitch, false, false, 0)
+	partitionsDelete = gtk.NewButtonWithLabel("Delete")
+	selectedBox.PackStart(partitionsDelete, false, false, 0)
+	partitionsBox.PackStart(selectedBox, false, false, 0)
+
+	newBox := gtk.NewHBox(false, 8)
+	partitionsName = gtk.NewEntry()
+	newBox.PackStart(partitionsName, true, true, 0)
+	partitionsNew = gtk.NewButtonWithLabel("New Partition")
+	newBox.PackStart(partitionsNew, false, false, 0)
+	partitionsBox.PackStart(newBox, false, false, 0)
+
+	partitionsWindow.Add(partitionsBox)
+
+	serverMenuPartition.Connect("activate", func() {
+		partitionsWindow.ShowAll()
+		partitionsWindow.Present()
+	})
+
+} // end initPartitionsWindow
+
+// SetPartitions replaces the partitions shown, marking the current one.
+func SetPartitions(partitions []string, current string) {
+
+	partitionsModel.Clear()
+	for _, partition := range partitions {
+		marker := ""
+		if partition == current {
+			marker = "Current"
+		}
+		var iter gtk.TreeIter
+		partitionsModel.Append(&iter)
+		partitionsModel.Set(&iter, partition, marker)
+	}
+
+} // end SetPartitions
+
+// selectedPartition returns the name of the selected partition, if any.
+func selectedPartition() (string, bool) {
+
+	var iter gtk.TreeIter
+	if !partitionsSelection.GetSelected(&iter) {
+		return "", false
+	}
+	var name glib.GValue
+	partitionsModel.GetValue(&iter, PARTITION_COL_NAME, &name)
+	return name.GetString(), true
+
+} // end selectedPartition
+
+// SetPartition shows the partition being controlled in the window title.
+func SetPartition(partition string) {
+
+	windowPartition = partition
+	setWindowTitle(windowSubject)
+
+} // end SetPartition
+
+// setWindowTitle sets the window title, adding Juke's name and (unless it
+// is the default) the partition being controlled.
+func setWindowTitle(subject string) {
+
+	windowSubject = subject
+	if windowPartition == "" || windowPartition == DEFAULT_PARTITION {
+		window.SetTitle(subject + " [Juke]")
+	} else {
+		window.SetTitle(subject + " [Juke: " + windowPartition + "]")
+	}
+
+} // end setWindowTitle
@@ -0,0 +1,146 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has the party mode requests window.
+*/
+
+package ui
+
+import (
+	"github.com/mattn/go-gtk/glib"
+	"github.com/mattn/go-gtk/gtk"
+)
+
+const (
+	PARTY_COL_ID int = iota
+	PARTY_COL_NAME
+	PARTY_COL_ARTIST
+	PARTY_COL_GUEST
+	PARTY_COL_VOTES
+	PARTY_COL_STATUS
+)
+
+// PartyRow is a guest's request as shown to the host.
+type PartyRow struct {
+	ID       int
+	Name     string
+	Artist   string
+	Guest    string
+	Votes    int
+	Approved bool
+}
+
+// Global referances for the party window.
+var (
+	partyWindow    *gtk.Window        // Party requests window
+	partyTree      *gtk.TreeView      // Treeview of the requests
+	partyModel     *gtk.ListStore     // Model of the requests
+	partySelection *gtk.TreeSelection // Selected request
+	partyApprove   *gtk.Button        // Approves the selected request
+	partyReject    *gtk.Button        // Rejects the selected request
+)
+
+// initPartyWindow builds the (initially hidden) party requests window.
+func initPartyWindow() {
+
+	partyWindow = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	partyWindow.SetTransientFor(window)
+	partyWindow.SetPosition(gtk.WIN_POS_CENTER)
+	partyWindow.SetTitle("Party Requests [Juke]")
+	partyWindow.SetDefaultSize(560, 320)
+	partyWindow.SetBorderWidth(8)
+	// Closing only hides the window, it is reused on the next opening.
+	partyWindow.Connect("delete-event", func() bool {
+		partyWindow.Hide()
+		return true
+	})
+
+	partyBox := gtk.NewVBox(false, 8)
+
+	partyModel = gtk.NewListStore(gtk.TYPE_INT, gtk.TYPE_STRING, gtk.TYPE_STRING, gtk.TYPE_STRING, gtk.TYPE_INT, gtk.TYPE_STRING)
+	partyTree = gtk.NewTreeView()
+	partyTree.SetModel(partyModel)
+	partyColNames := []string{"ID", "Name", "Artist", "Guest", "Votes", "Status"}
+	for ci := PARTY_COL_NAME; ci <= PARTY_COL_STATUS; ci++ {
+		partyCol := gtk.NewTreeViewColumnWithAttributes(partyColNames[ci], gtk.NewCellRendererText(), "text", ci)
+		partyCol.SetResizable(true)
+		partyTree.AppendColumn(partyCol)
+	}
+	partySelection = partyTree.GetSelection()
+	partySelection.SetMode(gtk.SELECTION_SINGLE)
+	partyScroll := gtk.NewScrolledWindow(nil, nil)
+	partyScroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_ALWAYS)
+	partyScroll.Add(partyTree)
+	partyBox.PackStart(partyScroll, true, true, 0)
+
+	buttonBox := gtk.NewHBox(false, 8)
+	partyApprove = gtk.NewButtonWithLabel("Approve")
+	buttonBox.PackStart(partyApprove, false, false, 0)
+	partyReject = gtk.NewButtonWithLabel("Reject")
+	buttonBox.PackStart(partyReject, false, false, 0)
+	partyBox.PackStart(buttonBox, false, false, 0)
+
+	partyWindow.Add(partyBox)
+
+} // end initPartyWindow
+
+// ShowPartyWindow brings the party requests window to the front.
+func ShowPartyWindow() {
+
+	partyWindow.ShowAll()
+	partyWindow.Present()
+
+} // end ShowPartyWindow
+
+// SetPartyRequests replaces the requests shown to the host.
+func SetPartyRequests(rows []*PartyRow) {
+
+	partyModel.Clear()
+	for _, row := range rows {
+		status := "Awaiting approval"
+		if row.Approved {
+			status = "Queued"
+		}
+		var iter gtk.TreeIter
+		partyModel.Append(&iter)
+		partyModel.Set(&iter, row.ID, row.Name, row.Artist, row.Guest, row.Votes, status)
+	}
+
+} // end SetPartyRequests
+
+// selectedPartyRequest returns the ID of the selected request, if any.
+func selectedPartyRequest() (int, bool) {
+
+	var iter gtk.TreeIter
+	if !partySelection.GetSelected(&iter) {
+		return 0, false
+	}
+	var id glib.GValue
+	partyModel.GetValue(&iter, PARTY_COL_ID, &id)
+	return id.GetInt(), true
+
+} // end selectedPartyRequest
+
+// SetPartyMode checks or unchecks the party mode item in the main menu.
+func SetPartyMode(enabled bool) {
+
+	mainMenuParty.SetActive(enabled)
+
+} // end SetPartyMode
+
+// SetControlsLocked locks (or unlocks) Juke's own playback and playlist
+// controls. The main menu stays usable so that the host can unlock them.
+func SetControlsLocked(locked bool) {
+
+	for i := range leftControls {
+		leftControls[i].SetSensitive(!locked)
+	}
+	for i := range playBackControls {
+		playBackControls[i].SetSensitive(!locked)
+	}
+	rightControls[VOLUME_BUTTON].SetSensitive(!locked)
+	rightControls[CONNECTION_BUTTON].SetSensitive(!locked)
+	progressBarEvent.SetSensitive(!locked)
+	playlistTree.SetSensitive(!locked)
+
+} // end SetControlsLocked
@@ -0,0 +1,224 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has the clock of the song progress, which moves the
+progress bar on smoothly between polls, and the ways of seeking on it.
+*/
+
+package ui
+
+import (
+	"github.com/idealeric/juke/log"
+	"github.com/mattn/go-gtk/gdk"
+	"github.com/mattn/go-gtk/glib"
+	"time"
+	"unsafe"
+)
+
+// How often the progress bar moves on while a song plays.
+const PROGRESS_TICK = 100 * time.Millisecond
+
+// Global referances for the song progress.
+var (
+	progressElapsed  float64   // Seconds into the song when last told (or paused)
+	progressDuration float64   // Length of the song in seconds (0 when stopped)
+	progressAnchor   time.Time // When progressElapsed was told
+	progressRunning  bool      // Whether the song is playing, so that the clock runs
+	progressEnded    bool      // Set once the main loop is over
+	progressLive     bool      // Whether a stream is playing, which has no duration
+
+	progressRemaining  bool                 // Show the time remaining rather than elapsed
+	progressScrollStep int                  // Seconds a scroll of the wheel seeks by (SEEK_STEP if unset)
+	progressDragging   bool                 // A button is down on the progress bar
+	progressDragMoved  bool                 // ... and has been dragged since
+	progressDragX      float64              // Where the drag is, in pixels
+	progressSeek       func(int, int) error // Seeks to a point of the bar (bound by ProgressBarClick)
+	remainingToggled   func(bool) error     // Keeps the display chosen (bound by RemainingToggle)
+)
+
+// progressAt returns how far into the song it is now.
+func progressAt() float64 {
+
+	at := progressElapsed
+	if progressRunning {
+		at += time.Since(progressAnchor).Seconds()
+	}
+	if progressDuration > 0 && at > progressDuration {
+		at = progressDuration
+	}
+	return at
+
+} // end progressAt
+
+// runProgress starts or stops the clock, from where it is now.
+func runProgress(running bool) {
+
+	progressElapsed = progressAt()
+	progressAnchor = time.Now()
+	progressRunning = running
+
+} // end runProgress
+
+// progressFraction returns how far along the progress bar a point (in
+// pixels) is, from 0 to 1.
+func progressFraction(x float64) float64 {
+
+	width := float64(progressBar.GetAllocation().Width)
+	if width <= 0 || x <= 0 {
+		return 0
+	}
+	if x >= width {
+		return 1
+	}
+	return x / width
+
+} // end progressFraction
+
+// drawProgress shows how far into the song it is now, or where it is
+// dragged to.
+func drawProgress() {
+
+	if progressLive {
+		// A stream can only tell how long it has played.
+		progressBar.SetText(formatDuration(int(progressAt())))
+		if progressRunning {
+			progressBar.Pulse()
+		}
+		setElapsed(0)
+		return
+	}
+	if progressDuration <= 0 {
+		return
+	}
+	at := progressAt()
+	if progressDragMoved {
+		at = progressFraction(progressDragX) * progressDuration
+	}
+	timeText := formatDuration(int(at))
+	if progressRemaining {
+		timeText = "-" + formatDuration(int(progressDuration-at))
+	}
+	progressBar.SetText(timeText + " / " + formatDuration(int(progressDuration)))
+	progressBar.SetFraction(at / progressDuration)
+	setElapsed(int(progressAt()))
+
+} // end drawProgress
+
+// tickProgress moves the progress bar on while a song plays, until the main
+// loop is over.
+func tickProgress() {
+
+	for range time.Tick(PROGRESS_TICK) {
+		Lock()
+		if progressEnded {
+			Unlock()
+			return
+		}
+		if progressRunning {
+			drawProgress()
+		}
+		Unlock()
+	}
+
+} // end tickProgress
+
+// toggleRemaining switches the time shown between elapsed and remaining.
+func toggleRemaining() bool {
+
+	progressRemaining = !progressRemaining
+	drawProgress()
+	if remainingToggled != nil {
+		if err := remainingToggled(progressRemaining); err != nil {
+			log.ErrorReport("UI toggleRemaining()", err.Error()+".")
+		}
+	}
+	return true
+
+} // end toggleRemaining
+
+// initProgressBar lets the progress bar be clicked, dragged and scrolled
+// to seek, with a tooltip telling the time under the pointer. A right
+// click switches between the time elapsed and the time remaining.
+func initProgressBar() {
+
+	progressBarEvent.AddEvents(int(gdk.POINTER_MOTION_MASK | gdk.BUTTON_PRESS_MASK | gdk.BUTTON_RELEASE_MASK | gdk.SCROLL_MASK))
+
+	progressBarEvent.Connect("button-press-event", func(cntx *glib.CallbackContext) bool {
+		arg := cntx.Args(0)
+		eventButton := *(**gdk.EventButton)(unsafe.Pointer(&arg))
+		if eventButton.Button == 1 {
+			progressDragging, progressDragMoved = true, false
+			progressDragX = eventButton.X
+		} else if eventButton.Button == 3 {
+			toggleRemaining()
+		}
+		return true
+	})
+
+	progressBarEvent.Connect("motion-notify-event", func(cntx *glib.CallbackContext) bool {
+		arg := cntx.Args(0)
+		eventMotion := *(**gdk.EventMotion)(unsafe.Pointer(&arg))
+		if progressLive {
+			progressBarEvent.SetTooltipText(STREAM_TOOLTIP)
+			return false
+		} else if progressDuration <= 0 {
+			progressBarEvent.SetTooltipText(STOPPED_OR_DC_PROGRESS)
+			return false
+		}
+		progressBarEvent.SetTooltipText(formatDuration(int(progressFraction(eventMotion.X) * progressDuration)))
+		if progressDragging && !progressLive {
+			// The song is only seeked once the button is released.
+			progressDragMoved = progressDragMoved || eventMotion.X != progressDragX
+			progressDragX = eventMotion.X
+			drawProgress()
+		}
+		return false
+	})
+
+	progressBarEvent.Connect("button-release-event", func(cntx *glib.CallbackContext) bool {
+		arg := cntx.Args(0)
+		eventButton := *(**gdk.EventButton)(unsafe.Pointer(&arg))
+		if eventButton.Button != 1 || !progressDragging {
+			return false
+		}
+		progressDragging, progressDragMoved = false, false
+		if progressSeek != nil && progressDuration > 0 {
+			// A drag may end off the bar, which seeks to its start or end.
+			width := progressBar.GetAllocation().Width
+			if err := progressSeek(int(progressFraction(eventButton.X)*float64(width)), width); err != nil {
+				log.ErrorReport("UI initProgressBar()", err.Error()+".")
+			}
+		}
+		drawProgress()
+		return true
+	})
+
+	progressBarEvent.Connect("scroll-event", func(cntx *glib.CallbackContext) bool {
+		arg := cntx.Args(0)
+		eventScroll := *(**gdk.EventScroll)(unsafe.Pointer(&arg))
+		step := progressScrollStep
+		if step <= 0 {
+			step = SEEK_STEP
+		}
+		switch eventScroll.Direction {
+		case gdk.SCROLL_UP, gdk.SCROLL_RIGHT:
+			seekBy(step)
+		case gdk.SCROLL_DOWN, gdk.SCROLL_LEFT:
+			seekBy(-step)
+		}
+		return true
+	})
+
+	registerCommand("toggle_remaining", "Show the time remaining or elapsed", toggleRemaining)
+
+} // end initProgressBar
+
+// SetProgressSettings sets how far a scroll of the wheel seeks (in seconds)
+// and whether the time remaining is shown rather than the time elapsed.
+func SetProgressSettings(scrollStep int, remaining bool) {
+
+	progressScrollStep = scrollStep
+	progressRemaining = remaining
+	drawProgress()
+
+} // end SetProgressSettings
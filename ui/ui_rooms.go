@@ -0,0 +1,104 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has the volume window and the multi-room group state.
+*/
+
+package ui
+
+import (
+	"github.com/mattn/go-gtk/gtk"
+	"strconv"
+	"strings"
+)
+
+// RoomState is what a member of the group is doing.
+type RoomState struct {
+	Name   string
+	State  string // play, pause, stop or unreachable
+	Volume int    // -1 if unknown or there is no mixer
+}
+
+// Global referances for the volume window and group state.
+var (
+	volumeWindow    *gtk.Window          // Volume window
+	volumeScale     *gtk.HScale          // Volume of the server (and group)
+	volumeSetting   bool                 // Whether the scale is being set by Juke (not the user)
+	roomStatesLabel *gtk.Label           // State of every member of the group
+	serverMenuGroup []*gtk.CheckMenuItem // Server menu items to group with, one per profile.
+)
+
+// initVolumeWindow builds the (initially hidden) volume window.
+func initVolumeWindow() {
+
+	volumeWindow = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	volumeWindow.SetTransientFor(window)
+	volumeWindow.SetPosition(gtk.WIN_POS_MOUSE)
+	volumeWindow.SetTitle("Volume [Juke]")
+	volumeWindow.SetDefaultSize(280, -1)
+	volumeWindow.SetBorderWidth(8)
+	// Closing only hides the window, it is reused on the next opening.
+	volumeWindow.Connect("delete-event", func() bool {
+		volumeWindow.Hide()
+		return true
+	})
+
+	volumeScale = gtk.NewHScaleWithRange(0, 100, 1)
+	volumeScale.SetDigits(0)
+	volumeScale.SetDrawValue(true)
+	volumeWindow.Add(volumeScale)
+
+	rightControls[VOLUME_BUTTON].Connect("released", func() {
+		if volumeWindow.GetVisible() {
+			volumeWindow.Hide()
+		} else {
+			volumeWindow.ShowAll()
+			volumeWindow.Present()
+		}
+	})
+
+} // end initVolumeWindow
+
+// SetVolume shows the volume of the server in use (-1 for none, which
+// means the server has no mixer).
+func SetVolume(volume int) {
+
+	volumeSetting = true
+	volumeScale.SetSensitive(volume >= 0)
+	if volume >= 0 {
+		volumeScale.SetValue(float64(volume))
+	}
+	volumeSetting = false
+
+} // end SetVolume
+
+// SetRoomStates shows the state of every member of the group (nothing at
+// all when there is no group).
+func SetRoomStates(states []*RoomState) {
+
+	if len(states) == 0 {
+		roomStatesLabel.Hide()
+		return
+	}
+
+	described := make([]string, len(states))
+	for i, state := range states {
+		switch state.State {
+		case "play":
+			described[i] = "playing"
+		case "pause":
+			described[i] = "paused"
+		case "stop":
+			described[i] = "stopped"
+		default:
+			described[i] = state.State
+		}
+		if state.Volume >= 0 {
+			described[i] += ", volume " + strconv.Itoa(state.Volume)
+		}
+		described[i] = "<b>" + escapeHTML(state.Name) + "</b> " + described[i]
+	}
+	roomStatesLabel.SetMarkup("<small>" + strings.Join(described, " · ") + "</small>")
+	roomStatesLabel.Show()
+
+} // end SetRoomStates
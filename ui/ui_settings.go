@@ -0,0 +1,125 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has the playback settings window (crossfade, MixRamp
+and ReplayGain).
+*/
+
+package ui
+
+import (
+	"github.com/mattn/go-gtk/gtk"
+)
+
+// Which playback setting has changed:
+type PlaybackSetting uint8
+
+const (
+	SETTING_CROSSFADE PlaybackSetting = iota
+	SETTING_MIXRAMP_DB
+	SETTING_MIXRAMP_DELAY
+	SETTING_REPLAY_GAIN
+)
+
+// MPD's ReplayGain modes, in the order they are offered.
+var REPLAY_GAIN_MODES = []string{"off", "track", "album", "auto"}
+
+// PlaybackSettings are the server's playback settings.
+type PlaybackSettings struct {
+	Crossfade    int     // seconds
+	MixRampDB    float64 // threshold in dB
+	MixRampDelay float64 // seconds, negative when MixRamp is off
+	ReplayGain   string  // one of REPLAY_GAIN_MODES
+}
+
+// Global referances for the playback settings window.
+var (
+	settingsWindow       *gtk.Window       // Playback settings window
+	settingsCrossfade    *gtk.SpinButton   // Crossfade in seconds
+	settingsMixRamp      *gtk.CheckButton  // Whether MixRamp is used
+	settingsMixRampDB    *gtk.SpinButton   // MixRamp threshold
+	settingsMixRampDelay *gtk.SpinButton   // MixRamp delay
+	settingsReplayGain   *gtk.ComboBoxText // ReplayGain mode
+	settingsUpdating     bool              // Whether the settings are being set by Juke (not the user)
+	mainMenuSettings     *gtk.MenuItem     // Main menu item for the playback settings window
+)
+
+// settingsRow packs a labelled control into the settings window.
+func settingsRow(box *gtk.VBox, label string, control gtk.IWidget) {
+
+	row := gtk.NewHBox(false, 8)
+	rowLabel := gtk.NewLabel(label)
+	rowLabel.SetSizeRequest(140, -1)
+	row.PackStart(rowLabel, false, false, 0)
+	row.PackStart(control, true, true, 0)
+	box.PackStart(row, false, false, 0)
+
+} // end settingsRow
+
+// initSettingsWindow builds the (initially hidden) playback settings window.
+func initSettingsWindow() {
+
+	settingsWindow = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	settingsWindow.SetTransientFor(window)
+	settingsWindow.SetPosition(gtk.WIN_POS_CENTER)
+	settingsWindow.SetTitle("Playback Settings [Juke]")
+	settingsWindow.SetBorderWidth(8)
+	// Closing only hides the window, it is reused on the next opening.
+	settingsWindow.Connect("delete-event", func() bool {
+		settingsWindow.Hide()
+		return true
+	})
+
+	settingsBox := gtk.NewVBox(false, 8)
+
+	settingsCrossfade = gtk.NewSpinButtonWithRange(0, 30, 1)
+	settingsRow(settingsBox, "Crossfade (seconds)", settingsCrossfade)
+
+	settingsMixRamp = gtk.NewCheckButtonWithLabel("Overlap songs with MixRamp")
+	settingsBox.PackStart(settingsMixRamp, false, false, 0)
+	settingsMixRampDB = gtk.NewSpinButtonWithRange(-60, 0, 0.5)
+	settingsMixRampDB.SetDigits(1)
+	settingsRow(settingsBox, "MixRamp threshold (dB)", settingsMixRampDB)
+	settingsMixRampDelay = gtk.NewSpinButtonWithRange(0, 10, 0.1)
+	settingsMixRampDelay.SetDigits(1)
+	settingsRow(settingsBox, "MixRamp delay (seconds)", settingsMixRampDelay)
+	settingsMixRamp.Connect("toggled", func() {
+		settingsMixRampDelay.SetSensitive(settingsMixRamp.GetActive())
+	})
+
+	settingsReplayGain = gtk.NewComboBoxText()
+	for _, mode := range REPLAY_GAIN_MODES {
+		settingsReplayGain.AppendText(mode)
+	}
+	settingsRow(settingsBox, "ReplayGain", settingsReplayGain)
+
+	settingsWindow.Add(settingsBox)
+
+	mainMenuSettings.Connect("activate", func() {
+		settingsWindow.ShowAll()
+		settingsWindow.Present()
+	})
+
+} // end initSettingsWindow
+
+// SetPlaybackSettings shows the server's playback settings.
+func SetPlaybackSettings(settings *PlaybackSettings) {
+
+	settingsUpdating = true
+
+	settingsCrossfade.SetValue(float64(settings.Crossfade))
+	settingsMixRampDB.SetValue(settings.MixRampDB)
+	settingsMixRamp.SetActive(settings.MixRampDelay >= 0)
+	settingsMixRampDelay.SetSensitive(settings.MixRampDelay >= 0)
+	if settings.MixRampDelay >= 0 {
+		settingsMixRampDelay.SetValue(settings.MixRampDelay)
+	}
+	for i, mode := range REPLAY_GAIN_MODES {
+		if mode == settings.ReplayGain {
+			settingsReplayGain.SetActive(i)
+		}
+	}
+
+	settingsUpdating = false
+
+} // end SetPlaybackSettings
@@ -0,0 +1,182 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has the listening statistics window.
+*/
+
+package ui
+
+import (
+	"github.com/mattn/go-gtk/gtk"
+	"strconv"
+	"time"
+)
+
+// Time ranges the statistics can be shown for:
+type StatsRange uint8
+
+const (
+	STATS_TODAY StatsRange = iota
+	STATS_WEEK
+	STATS_MONTH
+	STATS_YEAR
+	STATS_ALL_TIME
+	NUM_STATS_RANGES
+)
+
+const (
+	STATS_COL_LABEL int = iota
+	STATS_COL_COUNT
+)
+
+const (
+	HISTORY_COL_FILE int = iota
+	HISTORY_COL_PLAYED
+	HISTORY_COL_NAME
+	HISTORY_COL_ARTIST
+	HISTORY_COL_ALBUM
+)
+
+// StatsCount is a single line of a "top" list.
+type StatsCount struct {
+	Label string
+	Count int
+}
+
+// HistoryRow is a single listen in the recently played list.
+type HistoryRow struct {
+	File   string
+	Played time.Time
+	Name   string
+	Artist string
+	Album  string
+}
+
+// Statistics is everything the statistics window displays.
+type Statistics struct {
+	Range         StatsRange
+	Listens       int
+	ListeningTime int
+	TopArtists    []StatsCount
+	TopAlbums     []StatsCount
+	TopTracks     []StatsCount
+	Recent        []*HistoryRow
+}
+
+// Global referances for the statistics window.
+var (
+	statsWindow      *gtk.Window       // Statistics window
+	statsRangeCombo  *gtk.ComboBoxText // Time range selection
+	statsSummary     *gtk.Label        // Total listens and listening time
+	statsTopArtists  *gtk.ListStore    // Model for the top artists
+	statsTopAlbums   *gtk.ListStore    // Model for the top albums
+	statsTopTracks   *gtk.ListStore    // Model for the top tracks
+	statsRecentTree  *gtk.TreeView     // Treeview for the recently played songs
+	statsRecentModel *gtk.ListStore    // Model for the recently played songs
+)
+
+// newStatsTopList builds a scrolled two column (label, count) list.
+func newStatsTopList(title string) (*gtk.ScrolledWindow, *gtk.ListStore) {
+
+	model := gtk.NewListStore(gtk.TYPE_STRING, gtk.TYPE_INT)
+	tree := gtk.NewTreeView()
+	tree.SetModel(model)
+	labelCol := gtk.NewTreeViewColumnWithAttributes(title, gtk.NewCellRendererText(), "text", STATS_COL_LABEL)
+	labelCol.SetMinWidth(380)
+	tree.AppendColumn(labelCol)
+	tree.AppendColumn(gtk.NewTreeViewColumnWithAttributes("Plays", gtk.NewCellRendererText(), "text", STATS_COL_COUNT))
+	scroll := gtk.NewScrolledWindow(nil, nil)
+	scroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_ALWAYS)
+	scroll.Add(tree)
+	return scroll, model
+
+} // end newStatsTopList
+
+// initStatisticsWindow builds the (initially hidden) statistics window.
+func initStatisticsWindow() {
+
+	statsWindow = gtk.NewWindow(gtk.WINDOW_TOPLEVEL)
+	statsWindow.SetTransientFor(window)
+	statsWindow.SetPosition(gtk.WIN_POS_CENTER)
+	statsWindow.SetTitle("Listening Statistics [Juke]")
+	statsWindow.SetDefaultSize(520, 420)
+	statsWindow.SetBorderWidth(8)
+	// Closing only hides the window, it is reused on the next opening.
+	statsWindow.Connect("delete-event", func() bool {
+		statsWindow.Hide()
+		return true
+	})
+
+	statsBox := gtk.NewVBox(false, 8)
+
+	headerBox := gtk.NewHBox(false, 8)
+	statsRangeCombo = gtk.NewComboBoxText()
+	for _, rangeName := range []string{"Today", "Past Week", "Past Month", "Past Year", "All Time"} {
+		statsRangeCombo.AppendText(rangeName)
+	}
+	statsRangeCombo.SetActive(int(STATS_WEEK))
+	headerBox.PackStart(statsRangeCombo, false, false, 0)
+	statsSummary = gtk.NewLabel("")
+	headerBox.PackStart(statsSummary, false, false, 0)
+	statsBox.PackStart(headerBox, false, false, 0)
+
+	statsNotebook := gtk.NewNotebook()
+	var topScroll *gtk.ScrolledWindow
+	topScroll, statsTopArtists = newStatsTopList("Artist")
+	statsNotebook.AppendPage(topScroll, gtk.NewLabel("Top Artists"))
+	topScroll, statsTopAlbums = newStatsTopList("Album")
+	statsNotebook.AppendPage(topScroll, gtk.NewLabel("Top Albums"))
+	topScroll, statsTopTracks = newStatsTopList("Track")
+	statsNotebook.AppendPage(topScroll, gtk.NewLabel("Top Tracks"))
+
+	statsRecentModel = gtk.NewListStore(gtk.TYPE_STRING, gtk.TYPE_STRING, gtk.TYPE_STRING, gtk.TYPE_STRING, gtk.TYPE_STRING)
+	statsRecentTree = gtk.NewTreeView()
+	statsRecentTree.SetModel(statsRecentModel)
+	recentColNames := []string{"File", "Played", "Name", "Artist", "Album"}
+	for ci := HISTORY_COL_PLAYED; ci <= HISTORY_COL_ALBUM; ci++ {
+		recentCol := gtk.NewTreeViewColumnWithAttributes(recentColNames[ci], gtk.NewCellRendererText(), "text", ci)
+		recentCol.SetResizable(true)
+		statsRecentTree.AppendColumn(recentCol)
+	}
+	recentScroll := gtk.NewScrolledWindow(nil, nil)
+	recentScroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_ALWAYS)
+	recentScroll.Add(statsRecentTree)
+	statsNotebook.AppendPage(recentScroll, gtk.NewLabel("Recently Played"))
+
+	statsBox.PackStart(statsNotebook, true, true, 0)
+	statsBox.PackStart(gtk.NewLabel("Double-click a recently played song to add it to the playlist."), false, false, 0)
+	statsWindow.Add(statsBox)
+
+} // end initStatisticsWindow
+
+// fillStatsTopList replaces the contents of a "top" list.
+func fillStatsTopList(model *gtk.ListStore, counts []StatsCount) {
+
+	model.Clear()
+	for _, c := range counts {
+		var iter gtk.TreeIter
+		model.Append(&iter)
+		model.Set(&iter, c.Label, c.Count)
+	}
+
+} // end fillStatsTopList
+
+// ShowStatistics fills the statistics window and brings it to the front.
+func ShowStatistics(stats *Statistics) {
+
+	statsSummary.SetText(strconv.Itoa(stats.Listens) + " songs played, " + formatDuration(stats.ListeningTime) + " of listening")
+	fillStatsTopList(statsTopArtists, stats.TopArtists)
+	fillStatsTopList(statsTopAlbums, stats.TopAlbums)
+	fillStatsTopList(statsTopTracks, stats.TopTracks)
+
+	statsRecentModel.Clear()
+	for _, row := range stats.Recent {
+		var iter gtk.TreeIter
+		statsRecentModel.Append(&iter)
+		statsRecentModel.Set(&iter, row.File, row.Played.Format("Jan 2 3:04pm"), row.Name, row.Artist, row.Album)
+	}
+
+	statsWindow.ShowAll()
+	statsWindow.Present()
+
+} // end ShowStatistics
@@ -0,0 +1,143 @@
+/*
+This file is part of Juke MPD client ui package. See juke.go for more details.
+
+This particular file has the summary under the current playlist: the number
+of songs, their length, the time left to play and the selection's share.
+*/
+
+package ui
+
+import (
+	"github.com/mattn/go-gtk/glib"
+	"github.com/mattn/go-gtk/gtk"
+	"strconv"
+	"strings"
+)
+
+var (
+	summaryLabel    *gtk.Label // Label under the current playlist.
+	queueSongs      int        // Songs in the current playlist.
+	queueSeconds    int        // Length of the current playlist.
+	currentSeconds  int        // Length of the current song (0 if none).
+	afterSeconds    int        // Length of the songs after the current one.
+	elapsedSeconds  int        // Time into the current song.
+	selectedSongs   int        // Songs selected.
+	selectedSeconds int        // Length of the songs selected.
+)
+
+// initSummary adds the summary under the current playlist.
+func initSummary(mainBox *gtk.VBox) {
+
+	summaryLabel = gtk.NewLabel("")
+	summaryLabel.SetAlignment(0, 0.5)
+	mainBox.PackStart(summaryLabel, false, false, 0)
+	playlistSelection.Connect("changed", tallySelection)
+	showSummary()
+
+} // end initSummary
+
+// rowSeconds returns the length of a row's song (0 if unknown).
+func rowSeconds(iter *gtk.TreeIter) int {
+
+	var seconds glib.GValue
+	playlistModel.GetValue(iter, CUR_PL_COL_SECONDS, &seconds)
+	if seconds.GetInt() < 0 {
+		return 0
+	}
+	return seconds.GetInt()
+
+} // end rowSeconds
+
+// tallyQueue totals the current playlist, before and after the current
+// song. It is run when songs are added, removed or moved and when the
+// current song changes, the time left is kept up as the song plays.
+func tallyQueue() {
+
+	queueSongs, queueSeconds, currentSeconds, afterSeconds = 0, 0, 0, 0
+	current := false
+	var iter gtk.TreeIter
+	ok := playlistModel.GetIterFirst(&iter)
+	for ; ok; ok = playlistModel.IterNext(&iter) {
+		if isHeader(&iter) {
+			continue
+		}
+		seconds := rowSeconds(&iter)
+		queueSongs++
+		queueSeconds += seconds
+		var id glib.GValue
+		playlistModel.GetValue(&iter, CUR_PL_COL_ID, &id)
+		if id.GetInt() == currentBoldRow.ID {
+			current = true
+			currentSeconds = seconds
+		} else if current {
+			afterSeconds += seconds
+		}
+	}
+	if !current {
+		// Nothing is current, the whole playlist is left to play.
+		afterSeconds = queueSeconds
+	}
+	tallySelection()
+
+} // end tallyQueue
+
+// tallySelection totals the songs selected.
+func tallySelection() {
+
+	selectedSongs, selectedSeconds = 0, 0
+	if playlistSelection.CountSelectedRows() > 0 {
+		var iter gtk.TreeIter
+		ok := playlistModel.GetIterFirst(&iter)
+		for ; ok; ok = playlistModel.IterNext(&iter) {
+			path := playlistModel.GetPath(&iter)
+			if playlistSelection.PathIsSelected(path) && !isHeader(&iter) {
+				selectedSongs++
+				selectedSeconds += rowSeconds(&iter)
+			}
+			path.Free()
+		}
+	}
+	showSummary()
+
+} // end tallySelection
+
+// setElapsed keeps the time left up with the current song.
+func setElapsed(seconds int) {
+
+	if seconds != elapsedSeconds {
+		elapsedSeconds = seconds
+		showSummary()
+	}
+
+} // end setElapsed
+
+// showSummary shows the totals.
+func showSummary() {
+
+	if queueSongs == 0 {
+		summaryLabel.SetText("Empty playlist.")
+		return
+	}
+	left := currentSeconds - elapsedSeconds
+	if left < 0 {
+		left = 0
+	}
+	parts := []string{
+		songCount(queueSongs) + ", " + formatDuration(queueSeconds),
+		formatDuration(left+afterSeconds) + " left"}
+	if selectedSongs > 0 {
+		parts = append(parts, strconv.Itoa(selectedSongs)+" selected, "+formatDuration(selectedSeconds))
+	}
+	summaryLabel.SetText(strings.Join(parts, " · "))
+
+} // end showSummary
+
+// songCount says how many songs there are.
+func songCount(songs int) string {
+
+	if songs == 1 {
+		return "1 song"
+	}
+	return strconv.Itoa(songs) + " songs"
+
+} // end songCount